@@ -13,7 +13,7 @@ func DataSpecFromArtifactMetadata(meta *sori.ArtifactMetadata) *DataSpec {
 			Version:   meta.Identity.Version,
 		},
 		Data: DataSection{
-			ArtifactType:   "application/vnd.oci.image.manifest.v1+json",
+			ArtifactType:   sori.ArtifactTypeVolume,
 			Repository:     meta.Location.Repository,
 			Reference:      meta.Location.Reference,
 			ManifestDigest: meta.Location.ManifestDigest,
@@ -3,47 +3,143 @@ package archiveutil
 import (
 	"archive/tar"
 	"bytes"
-	"compress/gzip"
 	"errors"
+	"fmt"
 	"io"
 	"io/fs"
+	"math/rand/v2"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strings"
 	"time"
+
+	"github.com/opencontainers/go-digest"
 )
 
+// TarGzDir archives fsDir into an in-memory deterministic tar.gz whose entries
+// are rooted at prefixPath. Use WriteTarGz or TarGzDirToTempFile for large
+// directories.
 func TarGzDir(fsDir, prefixPath string) ([]byte, error) {
-	var entries []string
-	if err := filepath.WalkDir(fsDir, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return transportError("TarGzDir", "walk source directory", err)
-		}
-		entries = append(entries, path)
+	buf := &bytes.Buffer{}
+	if err := WriteTarGz(buf, fsDir, prefixPath, TarOptions{}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// TarOptions controls which parts of a directory WriteTarGz archives and how
+// the stream is compressed.
+type TarOptions struct {
+	// ExcludeDirs lists slash-separated directories, relative to the archived
+	// directory, whose whole subtree is left out of the archive.
+	ExcludeDirs []string
+	// Compression selects the stream compression. The zero value is gzip at
+	// best compression.
+	Compression Compression
+	// Progress, if set, is called by TarGzDirToTempFile with the running
+	// number of archive bytes written.
+	Progress func(written int64)
+	// OnFile, if set, is called by WriteTarGz for every regular file in the
+	// archive, hard links included, with its slash-separated archive name,
+	// size, and sha256 digest.
+	OnFile func(name string, size int64, sum digest.Digest)
+}
+
+// TempArchive is a compressed tar written to disk by TarGzDirToTempFile.
+type TempArchive struct {
+	Path   string
+	Digest digest.Digest
+	Size   int64
+}
+
+// Remove deletes the temporary archive file.
+func (a *TempArchive) Remove() error {
+	if a == nil || a.Path == "" {
 		return nil
-	}); err != nil {
+	}
+	if err := os.Remove(a.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return transportError("TempArchive.Remove", "remove "+a.Path, err)
+	}
+	return nil
+}
+
+// TarGzDirToTempFile streams the same deterministic tar.gz as TarGzDir into a
+// temporary file under tempDir, computing its digest and size on the way so
+// the archive never has to be held in memory.
+func TarGzDirToTempFile(fsDir, prefixPath, tempDir string, opts TarOptions) (*TempArchive, error) {
+	if tempDir != "" {
+		if err := os.MkdirAll(tempDir, 0o755); err != nil {
+			return nil, transportError("TarGzDirToTempFile", "create temp dir "+tempDir, err)
+		}
+	}
+	f, err := os.CreateTemp(tempDir, "sori-layer-*")
+	if err != nil {
+		return nil, transportError("TarGzDirToTempFile", "create temp file", err)
+	}
+	archive := &TempArchive{Path: f.Name()}
+
+	digester := digest.Canonical.Digester()
+	counter := &countingWriter{onWrite: opts.Progress}
+	if err := WriteTarGz(io.MultiWriter(f, digester.Hash(), counter), fsDir, prefixPath, opts); err != nil {
+		f.Close()
+		_ = archive.Remove()
 		return nil, err
 	}
-	sort.Strings(entries)
+	if err := f.Close(); err != nil {
+		_ = archive.Remove()
+		return nil, transportError("TarGzDirToTempFile", "close temp file "+archive.Path, err)
+	}
+	archive.Digest = digester.Digest()
+	archive.Size = counter.n
+	return archive, nil
+}
 
-	buf := &bytes.Buffer{}
-	gw, err := gzip.NewWriterLevel(buf, gzip.BestCompression)
+type countingWriter struct {
+	n       int64
+	onWrite func(int64)
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	if w.onWrite != nil {
+		w.onWrite(w.n)
+	}
+	return len(p), nil
+}
+
+// WriteTarGz streams a deterministic tar.gz of fsDir to w. Entries are sorted,
+// rooted at prefixPath, and carry zeroed ownership and timestamps so the same
+// tree always produces the same bytes. Directories listed in
+// opts.ExcludeDirs are skipped together with everything below them, and
+// opts.Compression may replace gzip with zstd or no compression.
+func WriteTarGz(w io.Writer, fsDir, prefixPath string, opts TarOptions) error {
+	if err := opts.Compression.Validate(); err != nil {
+		return err
+	}
+	entries, err := archiveEntries("WriteTarGz", fsDir, opts.ExcludeDirs)
+	if err != nil {
+		return err
+	}
+
+	cw, err := newCompressWriter(w, opts.Compression)
 	if err != nil {
-		return nil, transportError("TarGzDir", "create gzip writer", err)
+		return err
 	}
-	gw.Header.ModTime = time.Unix(0, 0)
-	gw.Header.OS = 0
 
-	tw := tar.NewWriter(gw)
+	tw := tar.NewWriter(cw)
+	hardLinks := make(map[fileID]string)
+	// sums remembers file digests so hard links can be reported to OnFile.
+	sums := make(map[string]digest.Digest)
 	for _, path := range entries {
 		info, err := os.Lstat(path)
 		if err != nil {
-			return nil, transportError("TarGzDir", "stat source path "+path, err)
+			return transportError("WriteTarGz", "stat source path "+path, err)
 		}
 		rel, err := filepath.Rel(fsDir, path)
 		if err != nil {
-			return nil, transportError("TarGzDir", "resolve relative path "+path, err)
+			return transportError("WriteTarGz", "resolve relative path "+path, err)
 		}
 
 		var tarName string
@@ -53,11 +149,33 @@ func TarGzDir(fsDir, prefixPath string) ([]byte, error) {
 			tarName = filepath.ToSlash(filepath.Join(prefixPath, rel))
 		}
 
-		hdr, err := tar.FileInfoHeader(info, "")
+		var linkTarget string
+		if info.Mode()&fs.ModeSymlink != 0 {
+			linkTarget, err = os.Readlink(path)
+			if err != nil {
+				return transportError("WriteTarGz", "read symlink "+path, err)
+			}
+			if err := validateLinkTarget("WriteTarGz", tarName, linkTarget); err != nil {
+				return err
+			}
+		}
+
+		hdr, err := tar.FileInfoHeader(info, linkTarget)
 		if err != nil {
-			return nil, transportError("TarGzDir", "build tar header for "+path, err)
+			return transportError("WriteTarGz", "build tar header for "+path, err)
 		}
 		hdr.Name = tarName
+		if info.Mode().IsRegular() {
+			if id, ok := hardLinkID(info); ok {
+				if first, seen := hardLinks[id]; seen {
+					hdr.Typeflag = tar.TypeLink
+					hdr.Linkname = first
+					hdr.Size = 0
+				} else {
+					hardLinks[id] = tarName
+				}
+			}
+		}
 		hdr.Uid = 0
 		hdr.Gid = 0
 		hdr.Uname = ""
@@ -65,47 +183,166 @@ func TarGzDir(fsDir, prefixPath string) ([]byte, error) {
 		hdr.ModTime = time.Unix(0, 0)
 
 		if err := tw.WriteHeader(hdr); err != nil {
-			return nil, transportError("TarGzDir", "write tar header for "+path, err)
+			return transportError("WriteTarGz", "write tar header for "+path, err)
 		}
-		if info.Mode().IsRegular() {
+		if hdr.Typeflag == tar.TypeReg {
 			f, err := os.Open(path)
 			if err != nil {
-				return nil, transportError("TarGzDir", "open source file "+path, err)
+				return transportError("WriteTarGz", "open source file "+path, err)
 			}
-			if _, err := io.Copy(tw, f); err != nil {
+			var dst io.Writer = tw
+			digester := digest.SHA256.Digester()
+			if opts.OnFile != nil {
+				dst = io.MultiWriter(tw, digester.Hash())
+			}
+			if _, err := io.Copy(dst, f); err != nil {
 				cErr := f.Close()
 				if cErr != nil {
-					return nil, transportError("TarGzDir", "copy source file "+path, errors.Join(err, cErr))
+					return transportError("WriteTarGz", "copy source file "+path, errors.Join(err, cErr))
 				}
-				return nil, transportError("TarGzDir", "copy source file "+path, err)
+				return transportError("WriteTarGz", "copy source file "+path, err)
 			}
 			if err := f.Close(); err != nil {
-				return nil, transportError("TarGzDir", "close source file "+path, err)
+				return transportError("WriteTarGz", "close source file "+path, err)
+			}
+			if opts.OnFile != nil {
+				sums[tarName] = digester.Digest()
+				opts.OnFile(tarName, hdr.Size, sums[tarName])
 			}
+		} else if hdr.Typeflag == tar.TypeLink && opts.OnFile != nil {
+			opts.OnFile(tarName, info.Size(), sums[hdr.Linkname])
 		}
 	}
 
 	if err := tw.Close(); err != nil {
-		return nil, transportError("TarGzDir", "close tar writer", err)
+		return transportError("WriteTarGz", "close tar writer", err)
 	}
-	if err := gw.Close(); err != nil {
-		return nil, transportError("TarGzDir", "close gzip writer", err)
+	if err := cw.Close(); err != nil {
+		return transportError("WriteTarGz", "close "+opts.Compression.AlgorithmName()+" writer", err)
 	}
-	return buf.Bytes(), nil
+	return nil
 }
 
+// archiveEntries returns the sorted paths under fsDir that WriteTarGz
+// archives, fsDir itself included.
+func archiveEntries(op, fsDir string, excludeDirs []string) ([]string, error) {
+	excluded := make(map[string]struct{}, len(excludeDirs))
+	for _, dir := range excludeDirs {
+		excluded[filepath.Clean(filepath.FromSlash(dir))] = struct{}{}
+	}
+
+	var entries []string
+	if err := filepath.WalkDir(fsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return transportError(op, "walk source directory", err)
+		}
+		if d.IsDir() && len(excluded) > 0 {
+			rel, err := filepath.Rel(fsDir, path)
+			if err != nil {
+				return transportError(op, "resolve relative path "+path, err)
+			}
+			if _, skip := excluded[rel]; skip {
+				return fs.SkipDir
+			}
+		}
+		entries = append(entries, path)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Strings(entries)
+	return entries, nil
+}
+
+// DirFingerprint digests the metadata of every entry WriteTarGz would archive
+// from fsDir with the given excluded directories: relative path, mode, size,
+// modification time, symlink target, and, where the platform has them, device,
+// inode, and link count. File contents are not read, so it is cheap on large
+// trees, but a file rewritten in place with its size and mtime restored keeps
+// the same fingerprint.
+func DirFingerprint(fsDir string, excludeDirs []string) (digest.Digest, error) {
+	entries, err := archiveEntries("DirFingerprint", fsDir, excludeDirs)
+	if err != nil {
+		return "", err
+	}
+	digester := digest.SHA256.Digester()
+	h := digester.Hash()
+	for _, path := range entries {
+		info, err := os.Lstat(path)
+		if err != nil {
+			return "", transportError("DirFingerprint", "stat source path "+path, err)
+		}
+		rel, err := filepath.Rel(fsDir, path)
+		if err != nil {
+			return "", transportError("DirFingerprint", "resolve relative path "+path, err)
+		}
+		var linkTarget string
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if linkTarget, err = os.Readlink(path); err != nil {
+				return "", transportError("DirFingerprint", "read symlink "+path, err)
+			}
+		}
+		fmt.Fprintf(h, "%q %o %d %d %q %s\n", filepath.ToSlash(rel), uint32(info.Mode()), info.Size(),
+			info.ModTime().UnixNano(), linkTarget, fileIdentity(info))
+	}
+	return digester.Digest(), nil
+}
+
+// validateLinkTarget is the symlink rule shared by archiving and extraction.
+// The target must be relative, may only climb with leading ".." components,
+// and must name a path strictly inside the archive once joined to the link's
+// directory. Climbing only at the front means the target never passes ".."
+// through another symlink, so the lexical check matches what the kernel
+// resolves on disk.
+func validateLinkTarget(op, tarName, linkTarget string) error {
+	if linkTarget == "" || filepath.IsAbs(linkTarget) || strings.HasPrefix(linkTarget, "/") {
+		return validationError(op, fmt.Sprintf("symlink %s has non-relative target %q", tarName, linkTarget), nil)
+	}
+	descended := false
+	for _, part := range strings.Split(filepath.ToSlash(linkTarget), "/") {
+		switch part {
+		case "", ".":
+		case "..":
+			if descended {
+				return validationError(op, fmt.Sprintf("symlink %s target %q climbs after descending", tarName, linkTarget), nil)
+			}
+		default:
+			descended = true
+		}
+	}
+	resolved := path.Join(path.Dir(filepath.ToSlash(tarName)), filepath.ToSlash(linkTarget))
+	if resolved == "." || resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return validationError(op, fmt.Sprintf("symlink %s target %q escapes the archive", tarName, linkTarget), nil)
+	}
+	return nil
+}
+
+// UntarGzDir extracts a tar.gz stream into dest without resource limits.
 func UntarGzDir(gzipStream io.Reader, dest string) error {
+	return UntarGzDirWithBudget(gzipStream, dest, nil)
+}
+
+// UntarGzDirWithBudget extracts a tar.gz stream into dest, charging every
+// entry against budget before it is written. Exceeding the budget stops the
+// extraction with an integrity error.
+func UntarGzDirWithBudget(gzipStream io.Reader, dest string, budget *ExtractBudget) error {
+	return UntarDirWithBudget(gzipStream, dest, CompressionGzip, budget)
+}
+
+// UntarDirWithBudget is UntarGzDirWithBudget for a tar stream compressed with
+// algorithm (CompressionNone, CompressionGzip, or CompressionZstd).
+func UntarDirWithBudget(stream io.Reader, dest, algorithm string, budget *ExtractBudget) error {
 	destRoot, err := filepath.Abs(dest)
 	if err != nil {
 		return transportError("UntarGzDir", "resolve destination "+dest, err)
 	}
-	gz, err := gzip.NewReader(gzipStream)
+	dr, err := newDecompressReader(stream, algorithm)
 	if err != nil {
-		return integrityError("UntarGzDir", "create gzip reader", err)
+		return err
 	}
-	defer gz.Close()
+	defer dr.Close()
 
-	tr := tar.NewReader(gz)
+	tr := tar.NewReader(dr)
 	for {
 		hdr, err := tr.Next()
 		if errors.Is(err, io.EOF) {
@@ -114,11 +351,17 @@ func UntarGzDir(gzipStream io.Reader, dest string) error {
 		if err != nil {
 			return integrityError("UntarGzDir", "read tar entry", err)
 		}
+		if err := budget.admit(hdr); err != nil {
+			return err
+		}
 
 		target, err := SecureJoinArchivePath(destRoot, hdr.Name)
 		if err != nil {
 			return err
 		}
+		if err := ensureNoSymlinkParents(destRoot, target); err != nil {
+			return err
+		}
 		mode := hdr.FileInfo().Mode()
 
 		switch hdr.Typeflag {
@@ -127,31 +370,42 @@ func UntarGzDir(gzipStream io.Reader, dest string) error {
 				return transportError("UntarGzDir", "mkdir "+target, err)
 			}
 		case tar.TypeReg, tar.TypeRegA:
-			parentDir := filepath.Dir(target)
-			if err := os.MkdirAll(parentDir, 0o755); err != nil {
-				return transportError("UntarGzDir", "mkdir parent "+parentDir, err)
+			// The file is written beside target and renamed over it, so a
+			// symlink already at target is replaced instead of followed.
+			if err := placeEntry(target, "file", func(path string) error {
+				f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode.Perm())
+				if err != nil {
+					return err
+				}
+				if _, err := io.Copy(f, tr); err != nil {
+					f.Close()
+					return err
+				}
+				return f.Close()
+			}); err != nil {
+				return err
 			}
-			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm())
-			if err != nil {
-				return transportError("UntarGzDir", "open file "+target, err)
+		case tar.TypeSymlink:
+			if err := validateLinkTarget("UntarGzDir", filepath.Clean(hdr.Name), hdr.Linkname); err != nil {
+				return err
 			}
-			if _, err := io.Copy(f, tr); err != nil {
-				f.Close()
-				return transportError("UntarGzDir", "copy file "+target, err)
+			if err := placeEntry(target, "symlink", func(path string) error {
+				return os.Symlink(hdr.Linkname, path)
+			}); err != nil {
+				return err
 			}
-			if err := f.Close(); err != nil {
-				return transportError("UntarGzDir", "close file "+target, err)
-			}
-		case tar.TypeSymlink:
-			if !filepath.IsLocal(hdr.Linkname) {
-				return validationError("UntarGzDir", "symlink target escapes destination", nil)
+		case tar.TypeLink:
+			source, err := SecureJoinArchivePath(destRoot, hdr.Linkname)
+			if err != nil {
+				return err
 			}
-			parentDir := filepath.Dir(target)
-			if err := os.MkdirAll(parentDir, 0o755); err != nil {
-				return transportError("UntarGzDir", "mkdir parent for symlink "+parentDir, err)
+			if err := ensureNoSymlinkParents(destRoot, source); err != nil {
+				return err
 			}
-			if err := os.Symlink(hdr.Linkname, target); err != nil {
-				return transportError("UntarGzDir", "create symlink "+target, err)
+			if err := placeEntry(target, "hard link", func(path string) error {
+				return os.Link(source, path)
+			}); err != nil {
+				return err
 			}
 		default:
 			continue
@@ -160,6 +414,74 @@ func UntarGzDir(gzipStream io.Reader, dest string) error {
 	return nil
 }
 
+// ensureNoSymlinkParents rejects targets whose parent directories below
+// destRoot are symlinks, so an archived link cannot redirect later entries
+// outside the destination.
+func ensureNoSymlinkParents(destRoot, target string) error {
+	rel, err := filepath.Rel(destRoot, filepath.Dir(target))
+	if err != nil {
+		return transportError("UntarGzDir", "resolve parent of "+target, err)
+	}
+	if rel == "." {
+		return nil
+	}
+	current := destRoot
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return transportError("UntarGzDir", "stat "+current, err)
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return validationError("UntarGzDir", "archive entry traverses symlink "+current, nil)
+		}
+	}
+	return nil
+}
+
+// placeEntry creates the kind of entry at target with create. The entry is
+// made under a temporary name in the same directory and renamed over target,
+// so whatever was at target, including a symlink, is replaced rather than
+// written through, and layers extracted in parallel that carry the same entry
+// both succeed. A directory at target is never replaced.
+func placeEntry(target, kind string, create func(path string) error) error {
+	parentDir := filepath.Dir(target)
+	if err := os.MkdirAll(parentDir, 0o755); err != nil {
+		return transportError("UntarGzDir", "mkdir parent for "+kind+" "+parentDir, err)
+	}
+	info, err := os.Lstat(target)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return transportError("UntarGzDir", "stat "+target, err)
+	}
+	if err == nil && info.IsDir() {
+		return validationError("UntarGzDir", kind+" would replace directory "+target, nil)
+	}
+
+	var tmp string
+	for attempt := 0; ; attempt++ {
+		tmp = filepath.Join(parentDir, fmt.Sprintf(".%s.sori-%016x", filepath.Base(target), rand.Uint64()))
+		err := create(tmp)
+		if err == nil {
+			break
+		}
+		if errors.Is(err, fs.ErrExist) && attempt < 10 {
+			continue
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			_ = os.Remove(tmp)
+		}
+		return transportError("UntarGzDir", "create "+kind+" "+target, err)
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		_ = os.Remove(tmp)
+		return transportError("UntarGzDir", "rename "+kind+" into "+target, err)
+	}
+	return nil
+}
+
 func SecureJoinArchivePath(destRoot, entryName string) (string, error) {
 	entry := filepath.Clean(entryName)
 	if entry == "." || entry == string(filepath.Separator) || entry == "" {
@@ -5,7 +5,12 @@ import (
 	"bytes"
 	"compress/gzip"
 	"errors"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
 )
 
 func TestSecureJoinArchivePath_PathTraversalTypedError(t *testing.T) {
@@ -47,3 +52,312 @@ func TestUntarGzDir_SymlinkEscapeTypedError(t *testing.T) {
 		t.Fatalf("expected ErrValidation, got %v", err)
 	}
 }
+
+func TestTarGzDirToTempFile_MatchesTarGzDir(t *testing.T) {
+	src := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "sub", "a.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	want, err := TarGzDir(src, "vol")
+	if err != nil {
+		t.Fatalf("TarGzDir: %v", err)
+	}
+	archive, err := TarGzDirToTempFile(src, "vol", t.TempDir(), TarOptions{})
+	if err != nil {
+		t.Fatalf("TarGzDirToTempFile: %v", err)
+	}
+	defer archive.Remove()
+
+	got, err := os.ReadFile(archive.Path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("streamed archive differs from in-memory archive")
+	}
+	if archive.Digest != digest.FromBytes(want) {
+		t.Fatalf("digest mismatch: got %s want %s", archive.Digest, digest.FromBytes(want))
+	}
+	if archive.Size != int64(len(want)) {
+		t.Fatalf("size mismatch: got %d want %d", archive.Size, len(want))
+	}
+}
+
+func TestTarGzDir_PreservesSymlinksAndHardLinks(t *testing.T) {
+	src := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "ref"), 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "ref", "GRCh38.fa"), []byte(">chr1\nACGT\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.Symlink("GRCh38.fa", filepath.Join(src, "ref", "genome.fa")); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+	if err := os.Link(filepath.Join(src, "ref", "GRCh38.fa"), filepath.Join(src, "ref", "hard.fa")); err != nil {
+		t.Fatalf("Link: %v", err)
+	}
+
+	data, err := TarGzDir(src, "vol")
+	if err != nil {
+		t.Fatalf("TarGzDir: %v", err)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	headers := make(map[string]*tar.Header)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		headers[hdr.Name] = hdr
+	}
+	if hdr := headers["vol/ref/genome.fa"]; hdr == nil || hdr.Typeflag != tar.TypeSymlink || hdr.Linkname != "GRCh38.fa" {
+		t.Fatalf("unexpected symlink header: %+v", hdr)
+	}
+	if hdr := headers["vol/ref/hard.fa"]; hdr == nil || hdr.Typeflag != tar.TypeLink || hdr.Linkname != "vol/ref/GRCh38.fa" {
+		t.Fatalf("unexpected hard link header: %+v", hdr)
+	}
+
+	dest := t.TempDir()
+	if err := UntarGzDir(bytes.NewReader(data), dest); err != nil {
+		t.Fatalf("UntarGzDir: %v", err)
+	}
+	link, err := os.Readlink(filepath.Join(dest, "vol", "ref", "genome.fa"))
+	if err != nil || link != "GRCh38.fa" {
+		t.Fatalf("Readlink: got %q, %v", link, err)
+	}
+	orig, err := os.Stat(filepath.Join(dest, "vol", "ref", "GRCh38.fa"))
+	if err != nil {
+		t.Fatalf("Stat original: %v", err)
+	}
+	hard, err := os.Stat(filepath.Join(dest, "vol", "ref", "hard.fa"))
+	if err != nil {
+		t.Fatalf("Stat hard link: %v", err)
+	}
+	if !os.SameFile(orig, hard) {
+		t.Fatal("expected hard link to share the original file")
+	}
+}
+
+func TestWriteTarGz_OnFileReportsRegularFilesAndHardLinks(t *testing.T) {
+	src := t.TempDir()
+	payload := []byte(">chr1\nACGT\n")
+	if err := os.WriteFile(filepath.Join(src, "GRCh38.fa"), payload, 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.Link(filepath.Join(src, "GRCh38.fa"), filepath.Join(src, "hard.fa")); err != nil {
+		t.Fatalf("Link: %v", err)
+	}
+	if err := os.Symlink("GRCh38.fa", filepath.Join(src, "genome.fa")); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+
+	type entry struct {
+		size int64
+		sum  digest.Digest
+	}
+	got := map[string]entry{}
+	opts := TarOptions{OnFile: func(name string, size int64, sum digest.Digest) {
+		got[name] = entry{size, sum}
+	}}
+	var buf bytes.Buffer
+	if err := WriteTarGz(&buf, src, "vol", opts); err != nil {
+		t.Fatalf("WriteTarGz: %v", err)
+	}
+
+	want := entry{int64(len(payload)), digest.FromBytes(payload)}
+	if len(got) != 2 || got["vol/GRCh38.fa"] != want || got["vol/hard.fa"] != want {
+		t.Fatalf("unexpected OnFile calls: %+v", got)
+	}
+}
+
+func TestTarGzDir_RejectsEscapingSymlinkTypedError(t *testing.T) {
+	src := t.TempDir()
+	if err := os.Symlink("../../etc/passwd", filepath.Join(src, "evil")); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+	_, err := TarGzDir(src, "vol")
+	if !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected ErrValidation, got %v", err)
+	}
+}
+
+func TestUntarGzDir_RejectsEntryThroughSymlinkTypedError(t *testing.T) {
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	for _, hdr := range []*tar.Header{
+		{Name: "vol/a/b/up", Typeflag: tar.TypeSymlink, Linkname: "../.."},
+		{Name: "vol/a/b/up/x", Typeflag: tar.TypeReg, Mode: 0o644},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("WriteHeader: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tar close: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("gzip close: %v", err)
+	}
+
+	err := UntarGzDir(bytes.NewReader(buf.Bytes()), t.TempDir())
+	if !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected ErrValidation, got %v", err)
+	}
+}
+
+// tarGzEntries builds a tar.gz stream of hdrs; regular entries hold data.
+func tarGzEntries(t *testing.T, data string, hdrs ...*tar.Header) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	for _, hdr := range hdrs {
+		if hdr.Typeflag == tar.TypeReg {
+			hdr.Size = int64(len(data))
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("WriteHeader: %v", err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(data)); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tar close: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("gzip close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestUntarGzDir_RejectsSymlinkChainEscape(t *testing.T) {
+	data := tarGzEntries(t, "escaped",
+		&tar.Header{Name: "vol/d/b", Typeflag: tar.TypeSymlink, Linkname: ".."},
+		&tar.Header{Name: "vol/d/c", Typeflag: tar.TypeSymlink, Linkname: "b/../../outside.txt"},
+		&tar.Header{Name: "vol/d/c", Typeflag: tar.TypeReg, Mode: 0o644},
+	)
+	parent := t.TempDir()
+	dest := filepath.Join(parent, "dest")
+	err := UntarGzDir(bytes.NewReader(data), dest)
+	if !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected ErrValidation, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(parent, "outside.txt")); !os.IsNotExist(err) {
+		t.Fatalf("extraction escaped the destination: %v", err)
+	}
+}
+
+func TestUntarGzDir_FileReplacesSymlink(t *testing.T) {
+	data := tarGzEntries(t, "inside",
+		&tar.Header{Name: "vol/target.txt", Typeflag: tar.TypeReg, Mode: 0o644},
+		&tar.Header{Name: "vol/link", Typeflag: tar.TypeSymlink, Linkname: "target.txt"},
+		&tar.Header{Name: "vol/link", Typeflag: tar.TypeReg, Mode: 0o644},
+	)
+	dest := t.TempDir()
+	if err := UntarGzDir(bytes.NewReader(data), dest); err != nil {
+		t.Fatalf("UntarGzDir: %v", err)
+	}
+	info, err := os.Lstat(filepath.Join(dest, "vol", "link"))
+	if err != nil || !info.Mode().IsRegular() {
+		t.Fatalf("expected the file to replace the symlink, got %v, %v", info, err)
+	}
+}
+
+func TestSymlinkRule_SameForArchiveAndExtract(t *testing.T) {
+	for _, tc := range []struct {
+		name, target string
+		ok           bool
+	}{
+		{"in-tree", "GRCh38.fa", true},
+		{"sibling dir", "../b/b.txt", true},
+		{"volume dir", "..", true},
+		{"above volume", "../..", false},
+		{"climb after descend", "b/../../x", false},
+		{"outside", "../../../x", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			src := t.TempDir()
+			if err := os.MkdirAll(filepath.Join(src, "a"), 0o755); err != nil {
+				t.Fatalf("MkdirAll: %v", err)
+			}
+			if err := os.Symlink(tc.target, filepath.Join(src, "a", "link")); err != nil {
+				t.Fatalf("Symlink: %v", err)
+			}
+			_, archiveErr := TarGzDir(src, "vol")
+			extractErr := UntarGzDir(bytes.NewReader(tarGzEntries(t, "",
+				&tar.Header{Name: "vol/a/link", Typeflag: tar.TypeSymlink, Linkname: tc.target},
+			)), t.TempDir())
+			for side, err := range map[string]error{"archive": archiveErr, "extract": extractErr} {
+				if tc.ok && err != nil {
+					t.Fatalf("%s rejected %q: %v", side, tc.target, err)
+				}
+				if !tc.ok && !errors.Is(err, ErrValidation) {
+					t.Fatalf("%s: expected ErrValidation for %q, got %v", side, tc.target, err)
+				}
+			}
+		})
+	}
+}
+
+func TestDirFingerprint_TracksMetadata(t *testing.T) {
+	dir := t.TempDir()
+	for rel, data := range map[string]string{"a.txt": "a", "skip/b.txt": "b"} {
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("MkdirAll: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	fingerprint := func() digest.Digest {
+		t.Helper()
+		d, err := DirFingerprint(dir, []string{"skip"})
+		if err != nil {
+			t.Fatalf("DirFingerprint: %v", err)
+		}
+		return d
+	}
+
+	first := fingerprint()
+	if again := fingerprint(); again != first {
+		t.Fatalf("fingerprint not stable: %s != %s", again, first)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "skip", "b.txt"), []byte("changed"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if got := fingerprint(); got != first {
+		t.Fatal("excluded directories must not affect the fingerprint")
+	}
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(dir, "a.txt"), mtime, mtime); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+	touched := fingerprint()
+	if touched == first {
+		t.Fatal("mtime change must change the fingerprint")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("longer"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.Chtimes(filepath.Join(dir, "a.txt"), mtime, mtime); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+	if fingerprint() == touched {
+		t.Fatal("size change must change the fingerprint")
+	}
+}
@@ -0,0 +1,156 @@
+package archiveutil
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Supported layer compression algorithms.
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// Compression selects how WriteTarGz compresses the tar stream. The zero value
+// is gzip at gzip.BestCompression, which keeps archives byte-identical to
+// those written before compression was configurable.
+type Compression struct {
+	// Algorithm is CompressionNone, CompressionGzip, or CompressionZstd.
+	// Empty means CompressionGzip.
+	Algorithm string
+	// Level is the algorithm-specific level: 1-9 for gzip and 1-22 for zstd
+	// (mapped onto the encoder's nearest speed). Zero selects the default,
+	// gzip.BestCompression or zstd level 3. It must be zero for
+	// CompressionNone.
+	Level int
+}
+
+// ParseCompression parses "none", "gzip", "zstd", or an algorithm with a
+// level such as "gzip:6" or "zstd:19". An empty string yields the default.
+func ParseCompression(s string) (Compression, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Compression{}, nil
+	}
+	algorithm, levelStr, hasLevel := strings.Cut(s, ":")
+	c := Compression{Algorithm: strings.ToLower(algorithm)}
+	if hasLevel {
+		level, err := strconv.Atoi(levelStr)
+		if err != nil {
+			return Compression{}, validationError("ParseCompression", fmt.Sprintf("invalid compression level in %q", s), err)
+		}
+		c.Level = level
+	}
+	if err := c.Validate(); err != nil {
+		return Compression{}, err
+	}
+	return c, nil
+}
+
+// Validate reports whether the algorithm and level are supported.
+func (c Compression) Validate() error {
+	switch c.AlgorithmName() {
+	case CompressionNone:
+		if c.Level != 0 {
+			return validationError("Compression.Validate", "compression level is not allowed for none", nil)
+		}
+	case CompressionGzip:
+		if c.Level < 0 || c.Level > gzip.BestCompression {
+			return validationError("Compression.Validate", fmt.Sprintf("gzip level %d out of range 1-9", c.Level), nil)
+		}
+	case CompressionZstd:
+		if c.Level < 0 || c.Level > 22 {
+			return validationError("Compression.Validate", fmt.Sprintf("zstd level %d out of range 1-22", c.Level), nil)
+		}
+	default:
+		return validationError("Compression.Validate", fmt.Sprintf("unsupported compression %q", c.Algorithm), nil)
+	}
+	return nil
+}
+
+// String returns the canonical algorithm name, with ":level" when a
+// non-default level is set.
+func (c Compression) String() string {
+	if c.Level == 0 {
+		return c.AlgorithmName()
+	}
+	return c.AlgorithmName() + ":" + strconv.Itoa(c.Level)
+}
+
+// AlgorithmName returns the algorithm with the empty default resolved.
+func (c Compression) AlgorithmName() string {
+	if c.Algorithm == "" {
+		return CompressionGzip
+	}
+	return c.Algorithm
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// newCompressWriter wraps w so that everything written is compressed with c.
+// Closing the returned writer flushes the compressor but does not close w.
+func newCompressWriter(w io.Writer, c Compression) (io.WriteCloser, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	switch c.AlgorithmName() {
+	case CompressionNone:
+		return nopWriteCloser{w}, nil
+	case CompressionZstd:
+		level := c.Level
+		if level == 0 {
+			level = 3
+		}
+		zw, err := zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		if err != nil {
+			return nil, transportError("WriteTarGz", "create zstd writer", err)
+		}
+		return zw, nil
+	default:
+		level := c.Level
+		if level == 0 {
+			level = gzip.BestCompression
+		}
+		gw, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			return nil, transportError("WriteTarGz", "create gzip writer", err)
+		}
+		gw.Header.ModTime = time.Unix(0, 0)
+		gw.Header.OS = 0
+		return gw, nil
+	}
+}
+
+// newDecompressReader returns a reader that decompresses r according to
+// algorithm.
+func newDecompressReader(r io.Reader, algorithm string) (io.ReadCloser, error) {
+	switch algorithm {
+	case CompressionNone:
+		return io.NopCloser(r), nil
+	case CompressionZstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, integrityError("UntarGzDir", "create zstd reader", err)
+		}
+		return zr.IOReadCloser(), nil
+	case "", CompressionGzip:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, integrityError("UntarGzDir", "create gzip reader", err)
+		}
+		return gz, nil
+	default:
+		return nil, validationError("UntarGzDir", fmt.Sprintf("unsupported compression %q", algorithm), nil)
+	}
+}
@@ -0,0 +1,60 @@
+package archiveutil
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteTarGz_CompressionRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	payload := strings.Repeat("ACGT", 4096)
+	if err := os.WriteFile(filepath.Join(src, "sub", "a.fa"), []byte(payload), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	for _, spec := range []string{"none", "gzip:1", "zstd", "zstd:19"} {
+		t.Run(spec, func(t *testing.T) {
+			c, err := ParseCompression(spec)
+			if err != nil {
+				t.Fatalf("ParseCompression: %v", err)
+			}
+			var first, second bytes.Buffer
+			if err := WriteTarGz(&first, src, "vol", TarOptions{Compression: c}); err != nil {
+				t.Fatalf("WriteTarGz: %v", err)
+			}
+			if err := WriteTarGz(&second, src, "vol", TarOptions{Compression: c}); err != nil {
+				t.Fatalf("WriteTarGz again: %v", err)
+			}
+			if !bytes.Equal(first.Bytes(), second.Bytes()) {
+				t.Fatal("archive is not deterministic")
+			}
+
+			dest := t.TempDir()
+			if err := UntarDirWithBudget(bytes.NewReader(first.Bytes()), dest, c.AlgorithmName(), nil); err != nil {
+				t.Fatalf("UntarDirWithBudget: %v", err)
+			}
+			got, err := os.ReadFile(filepath.Join(dest, "vol", "sub", "a.fa"))
+			if err != nil {
+				t.Fatalf("ReadFile: %v", err)
+			}
+			if string(got) != payload {
+				t.Fatal("restored content mismatch")
+			}
+		})
+	}
+}
+
+func TestParseCompression_InvalidTypedError(t *testing.T) {
+	for _, spec := range []string{"lz4", "gzip:10", "zstd:x", "none:1"} {
+		if _, err := ParseCompression(spec); !errors.Is(err, ErrValidation) {
+			t.Fatalf("%q: expected ErrValidation, got %v", spec, err)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package archiveutil
+
+import "io/fs"
+
+type fileID struct{}
+
+// hardLinkID reports no hard links on platforms without inode numbers, so
+// linked files are archived as regular files.
+func hardLinkID(fs.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
+
+// fileIdentity is empty on platforms without inode numbers.
+func fileIdentity(fs.FileInfo) string {
+	return ""
+}
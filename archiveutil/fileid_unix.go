@@ -0,0 +1,33 @@
+//go:build unix
+
+package archiveutil
+
+import (
+	"fmt"
+	"io/fs"
+	"syscall"
+)
+
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// hardLinkID identifies files that share an inode with another path.
+func hardLinkID(info fs.FileInfo) (fileID, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || st.Nlink < 2 {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+}
+
+// fileIdentity returns the device, inode, and link count of info for
+// DirFingerprint.
+func fileIdentity(info fs.FileInfo) string {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%d:%d:%d", uint64(st.Dev), uint64(st.Ino), uint64(st.Nlink))
+}
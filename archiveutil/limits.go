@@ -0,0 +1,73 @@
+package archiveutil
+
+import (
+	"archive/tar"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+)
+
+// ExtractLimits bounds what UntarGzDirWithBudget may write. A zero field
+// means no limit for that dimension.
+type ExtractLimits struct {
+	// MaxTotalBytes caps the regular-file bytes written across every archive
+	// sharing the budget.
+	MaxTotalBytes int64
+	// MaxFileBytes caps the size of any single regular file.
+	MaxFileBytes int64
+	// MaxEntries caps the number of archive entries across every archive
+	// sharing the budget.
+	MaxEntries int
+	// MaxPathDepth caps the number of path components in an entry name.
+	MaxPathDepth int
+}
+
+// ExtractBudget tracks ExtractLimits usage across one or more extractions. It
+// is safe for concurrent use, so parallel layer extraction can share one
+// budget. A nil budget imposes no limits.
+type ExtractBudget struct {
+	limits ExtractLimits
+
+	mu         sync.Mutex
+	totalBytes int64
+	entries    int
+}
+
+// NewExtractBudget returns a budget enforcing limits.
+func NewExtractBudget(limits ExtractLimits) *ExtractBudget {
+	return &ExtractBudget{limits: limits}
+}
+
+// admit charges hdr against the budget before the entry is written.
+func (b *ExtractBudget) admit(hdr *tar.Header) error {
+	if b == nil {
+		return nil
+	}
+	if b.limits.MaxPathDepth > 0 {
+		depth := len(strings.Split(path.Clean(strings.TrimPrefix(hdr.Name, "./")), "/"))
+		if depth > b.limits.MaxPathDepth {
+			return integrityError("UntarGzDir", fmt.Sprintf("entry %s exceeds max path depth %d", hdr.Name, b.limits.MaxPathDepth), nil)
+		}
+	}
+
+	var size int64
+	if hdr.Typeflag == tar.TypeReg || hdr.Typeflag == tar.TypeRegA {
+		size = hdr.Size
+		if b.limits.MaxFileBytes > 0 && size > b.limits.MaxFileBytes {
+			return integrityError("UntarGzDir", fmt.Sprintf("entry %s size %d exceeds max file size %d", hdr.Name, size, b.limits.MaxFileBytes), nil)
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.limits.MaxEntries > 0 && b.entries+1 > b.limits.MaxEntries {
+		return integrityError("UntarGzDir", fmt.Sprintf("archive exceeds max entry count %d", b.limits.MaxEntries), nil)
+	}
+	if b.limits.MaxTotalBytes > 0 && b.totalBytes+size > b.limits.MaxTotalBytes {
+		return integrityError("UntarGzDir", fmt.Sprintf("extraction exceeds max total size %d bytes", b.limits.MaxTotalBytes), nil)
+	}
+	b.entries++
+	b.totalBytes += size
+	return nil
+}
@@ -0,0 +1,43 @@
+package archiveutil
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUntarGzDirWithBudget_Limits(t *testing.T) {
+	src := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "a", "b"), 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "a", "b", "big.txt"), bytes.Repeat([]byte("x"), 1024), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	data, err := TarGzDir(src, "vol")
+	if err != nil {
+		t.Fatalf("TarGzDir: %v", err)
+	}
+
+	cases := map[string]ExtractLimits{
+		"total bytes": {MaxTotalBytes: 512},
+		"file bytes":  {MaxFileBytes: 1023},
+		"entries":     {MaxEntries: 2},
+		"path depth":  {MaxPathDepth: 3},
+	}
+	for name, limits := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := UntarGzDirWithBudget(bytes.NewReader(data), t.TempDir(), NewExtractBudget(limits))
+			if !errors.Is(err, ErrIntegrity) {
+				t.Fatalf("expected ErrIntegrity, got %v", err)
+			}
+		})
+	}
+
+	within := ExtractLimits{MaxTotalBytes: 1024, MaxFileBytes: 1024, MaxEntries: 4, MaxPathDepth: 4}
+	if err := UntarGzDirWithBudget(bytes.NewReader(data), t.TempDir(), NewExtractBudget(within)); err != nil {
+		t.Fatalf("expected extraction within limits to succeed, got %v", err)
+	}
+}
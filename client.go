@@ -2,9 +2,15 @@ package sori
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"os"
+	"strings"
 	"time"
+
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content/oci"
+	"oras.land/oras-go/v2/registry"
 )
 
 // Client is the preferred entrypoint for the core packaging, push, and fetch
@@ -15,6 +21,8 @@ type Client struct {
 	localStorePath string
 	httpClient     *http.Client
 	now            func() time.Time
+	progress       ProgressReporter
+	config         *Config
 }
 
 // ClientOption configures the preferred Client-based core path.
@@ -78,7 +86,7 @@ func (c *Client) PackageVolume(ctx context.Context, req PackageRequest) (*Packag
 // core path with explicit core packaging options.
 func (c *Client) PackageVolumeWithOptions(ctx context.Context, req PackageRequest, opts PackageOptions) (*PackageResult, error) {
 	req.ConfigBlob = opts.ConfigBlob
-	return packageVolumeToStoreWithOptions(ctx, c.localStorePath, req, opts)
+	return packageVolumeToStoreWithOptions(ctx, c.localStorePath, req, opts, newProgressSink(c.progress, ProgressPackage))
 }
 
 // PushPackagedVolume pushes a packaged dataset using the preferred client-based
@@ -94,7 +102,20 @@ func (c *Client) PushPackagedVolumeWithOptions(ctx context.Context, pkg *Package
 	if c.httpClient != nil {
 		target.HTTPClient = c.httpClient
 	}
-	return PushPackagedVolume(ctx, c.localStorePath, pkg, target)
+	return pushPackagedVolume(ctx, c.localStorePath, pkg, target, newProgressSink(c.progress, ProgressPush))
+}
+
+// PushToRemote pushes a packaged dataset to the remote named remoteName in the
+// configuration the client was built from with Config.NewClient.
+func (c *Client) PushToRemote(ctx context.Context, pkg *PackageResult, remoteName string) (*PushResult, error) {
+	if c.config == nil {
+		return nil, validationError("Client.PushToRemote", "client was not built from a Config", nil)
+	}
+	target, err := c.config.RemoteTarget(remoteName)
+	if err != nil {
+		return nil, err
+	}
+	return c.PushPackagedVolumeWithOptions(ctx, pkg, PushOptions{Target: target})
 }
 
 // FetchVolumeSequential fetches a packaged dataset using the preferred client
@@ -111,16 +132,71 @@ func (c *Client) FetchVolumeParallel(ctx context.Context, destRoot, repo, tag st
 
 // FetchVolume fetches a packaged dataset using the preferred client-based core
 // path and core fetch options.
+//
+// repo is a local OCI layout path unless opts.Remote is set, in which case the
+// dataset is streamed straight from the remote registry. When opts.Remote
+// leaves Registry and Repository empty, repo is read as a registry reference
+// such as "harbor.local/project/repo".
 func (c *Client) FetchVolume(ctx context.Context, destRoot, repo, tag string, opts FetchOptions) (*VolumeIndex, error) {
+	if opts.Incremental && (opts.Atomic || opts.RequireEmptyDestination) {
+		return nil, validationError("FetchVolume", "incremental fetch cannot be combined with atomic or require-empty fetch", nil)
+	}
 	if opts.RequireEmptyDestination {
 		if err := ensureEmptyDir(destRoot); err != nil {
 			return nil, err
 		}
 	}
-	if opts.Concurrency <= 1 {
-		return FetchVolSeq(ctx, destRoot, repo, tag)
+	src, srcName, err := c.openFetchSource(repo, tag, opts)
+	if err != nil {
+		return nil, err
+	}
+	return fetchVolumeFromTarget(ctx, "FetchVolume", src, srcName, destRoot, tag, opts, newProgressSink(c.progress, ProgressFetch))
+}
+
+// openFetchSource returns the local OCI layout at repo, or the remote
+// repository described by opts.Remote, optionally behind the pull-through
+// cache in the client's local store.
+func (c *Client) openFetchSource(repo, ref string, opts FetchOptions) (oras.ReadOnlyTarget, string, error) {
+	if opts.Remote == nil {
+		store, err := oci.New(repo)
+		if err != nil {
+			return nil, "", transportError("FetchVolume", "open OCI store", err)
+		}
+		return store, repo, nil
+	}
+
+	target, err := c.resolveFetchTarget(repo, *opts.Remote)
+	if err != nil {
+		return nil, "", err
+	}
+	remoteRepo, remoteName, err := openRemoteFetchRepository("FetchVolume", target, ref)
+	if err != nil {
+		return nil, "", err
+	}
+	if !opts.PullThroughCache {
+		return remoteRepo, remoteName, nil
+	}
+	cached, err := newPullThroughTarget(remoteRepo, c.localStorePath, remoteName)
+	if err != nil {
+		return nil, "", err
+	}
+	return cached, remoteName, nil
+}
+
+func (c *Client) resolveFetchTarget(repo string, target RemoteTarget) (RemoteTarget, error) {
+	if c.httpClient != nil {
+		target.HTTPClient = c.httpClient
+	}
+	if target.Registry != "" || target.Repository != "" {
+		return target, nil
+	}
+	ref, err := registry.ParseReference(strings.TrimSpace(repo))
+	if err != nil {
+		return target, validationError("FetchVolume", fmt.Sprintf("parse registry reference %q", repo), err)
 	}
-	return FetchVolParallel(ctx, destRoot, repo, tag, opts.Concurrency)
+	target.Registry = ref.Registry
+	target.Repository = ref.Repository
+	return target, nil
 }
 
 func ensureEmptyDir(path string) error {
@@ -142,7 +218,7 @@ func ensureEmptyDir(path string) error {
 // This method exists for callers that still operate at the VolumeIndex level,
 // but the preferred core path for new code is PackageVolumeWithOptions.
 func (c *Client) PublishVolume(ctx context.Context, vi *VolumeIndex, volPath, volName string, configBlob []byte) (*VolumeIndex, error) {
-	return vi.publishVolumeToStore(ctx, c.localStorePath, volPath, volName, configBlob)
+	return vi.publishVolumeToStore(ctx, c.localStorePath, volPath, volName, configBlob, publishOptions{})
 }
 
 // PublishVolumeFromDir is a convenience wrapper over the preferred client
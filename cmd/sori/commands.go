@@ -0,0 +1,421 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/seoyhaein/sori"
+)
+
+// keyValues collects repeated key=value flags.
+type keyValues map[string]string
+
+func (kv keyValues) String() string {
+	pairs := make([]string, 0, len(kv))
+	for k, v := range kv {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (kv keyValues) Set(s string) error {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok || strings.TrimSpace(k) == "" {
+		return fmt.Errorf("expected key=value, got %q", s)
+	}
+	kv[k] = v
+	return nil
+}
+
+// stringList collects a repeated flag.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+func requireFlags(pairs ...string) error {
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if strings.TrimSpace(pairs[i+1]) == "" {
+			return &usageError{msg: fmt.Sprintf("-%s is required", pairs[i])}
+		}
+	}
+	return nil
+}
+
+func runPackage(env *cmdEnv, args []string) error {
+	fs := env.newFlagSet("package")
+	var req sori.PackageRequest
+	var opts sori.PackageOptions
+	annotations := keyValues{}
+	fs.StringVar(&req.SourceDir, "src", "", "dataset directory to package (required)")
+	fs.StringVar(&req.DisplayName, "name", "", "display name (required)")
+	fs.StringVar(&req.Tag, "tag", "", "local tag (required)")
+	fs.StringVar(&req.Dataset, "dataset", "", "dataset name")
+	fs.StringVar(&req.Version, "version", "", "dataset version")
+	fs.StringVar(&req.Description, "description", "", "dataset description")
+	fs.StringVar(&req.StableRef, "stable-ref", "", "stable reference (default dataset:version)")
+	fs.StringVar(&opts.PartitionLayout, "layout", "", "partition layout: nested or exclusive")
+	fs.StringVar(&opts.Compression, "compression", "", "layer compression: gzip[:level], zstd[:level], or none")
+	fs.BoolVar(&opts.FullVerification, "full-verify", false, "archive every partition even if the packaging cache says it is unchanged")
+	fs.Var(annotations, "annotation", "manifest annotation key=value (repeatable)")
+	if err := env.parse(fs, args); err != nil {
+		return err
+	}
+	if err := requireFlags("src", req.SourceDir, "name", req.DisplayName, "tag", req.Tag); err != nil {
+		return err
+	}
+	if len(annotations) > 0 {
+		req.Annotations = annotations
+	}
+
+	cfg, err := env.loadConfig()
+	if err != nil {
+		return err
+	}
+	pkg, err := cfg.NewClient().PackageVolumeWithOptions(context.Background(), req, opts)
+	if err != nil {
+		return err
+	}
+	return env.print(pkg, func(w io.Writer) {
+		fmt.Fprintf(w, "packaged %s %s (%d bytes)\n", pkg.LocalTag, pkg.ManifestDigest, pkg.TotalSize)
+		for _, p := range pkg.Partitions {
+			fmt.Fprintf(w, "  %s %s\n", p.ManifestRef, p.Path)
+		}
+	})
+}
+
+func runPush(env *cmdEnv, args []string) error {
+	fs := env.newFlagSet("push")
+	var remoteName, tag string
+	fs.StringVar(&remoteName, "remote", "", "remote name from the config (required)")
+	fs.StringVar(&tag, "tag", "", "local tag to push (required)")
+	if err := env.parse(fs, args); err != nil {
+		return err
+	}
+	if err := requireFlags("remote", remoteName, "tag", tag); err != nil {
+		return err
+	}
+
+	cfg, err := env.loadConfig()
+	if err != nil {
+		return err
+	}
+	res, err := cfg.NewClient().PushToRemote(context.Background(), &sori.PackageResult{LocalTag: tag}, remoteName)
+	if err != nil {
+		return err
+	}
+	return env.print(res, func(w io.Writer) {
+		fmt.Fprintf(w, "pushed %s %s\n", res.Reference, res.ManifestDigest)
+	})
+}
+
+func runFetch(env *cmdEnv, args []string) error {
+	fs := env.newFlagSet("fetch")
+	var tag, dest, remoteName string
+	var opts sori.FetchOptions
+	fs.StringVar(&tag, "tag", "", "tag or manifest digest to fetch (required)")
+	fs.StringVar(&dest, "dest", "", "destination directory (required)")
+	fs.StringVar(&remoteName, "remote", "", "fetch from this configured remote instead of the local store")
+	fs.BoolVar(&opts.PullThroughCache, "cache", false, "with -remote, cache fetched blobs in the local store")
+	fs.IntVar(&opts.Concurrency, "concurrency", runtime.NumCPU(), "layers extracted in parallel")
+	fs.BoolVar(&opts.RequireEmptyDestination, "require-empty", false, "fail unless the destination is empty")
+	fs.BoolVar(&opts.Atomic, "atomic", false, "extract into a staging directory and rename it into place")
+	fs.BoolVar(&opts.Incremental, "incremental", false, "keep partitions of a previous fetch in -dest whose layer is unchanged")
+	fs.Var((*stringList)(&opts.Partitions), "partition", "fetch only partitions matching this path or glob (repeatable)")
+	if err := env.parse(fs, args); err != nil {
+		return err
+	}
+	if err := requireFlags("tag", tag, "dest", dest); err != nil {
+		return err
+	}
+	if opts.PullThroughCache && remoteName == "" {
+		return &usageError{msg: "-cache requires -remote"}
+	}
+
+	cfg, err := env.loadConfig()
+	if err != nil {
+		return err
+	}
+	repo := cfg.Local.Path
+	if remoteName != "" {
+		target, err := cfg.RemoteTarget(remoteName)
+		if err != nil {
+			return err
+		}
+		opts.Remote = &target
+		repo = ""
+	}
+	vi, err := cfg.NewClient().FetchVolume(context.Background(), dest, repo, tag, opts)
+	if err != nil {
+		return err
+	}
+	return env.print(vi, func(w io.Writer) {
+		fmt.Fprintf(w, "fetched %s into %s\n", vi.VolumeRef, dest)
+		for _, p := range vi.Partitions {
+			fmt.Fprintf(w, "  %s %s\n", p.ManifestRef, p.Path)
+		}
+	})
+}
+
+func runInspect(env *cmdEnv, args []string) error {
+	fs := env.newFlagSet("inspect")
+	var tag, remoteName string
+	fs.StringVar(&tag, "tag", "", "tag or manifest digest to inspect (required)")
+	fs.StringVar(&remoteName, "remote", "", "inspect on this configured remote instead of the local store")
+	if err := env.parse(fs, args); err != nil {
+		return err
+	}
+	if err := requireFlags("tag", tag); err != nil {
+		return err
+	}
+
+	cfg, err := env.loadConfig()
+	if err != nil {
+		return err
+	}
+	client := cfg.NewClient()
+	var res *sori.Inspection
+	if remoteName != "" {
+		target, err := cfg.RemoteTarget(remoteName)
+		if err != nil {
+			return err
+		}
+		res, err = client.InspectRemote(context.Background(), target, tag)
+		if err != nil {
+			return err
+		}
+	} else {
+		res, err = client.InspectLocal(context.Background(), tag)
+		if err != nil {
+			return err
+		}
+	}
+	return env.print(res, func(w io.Writer) {
+		fmt.Fprintf(w, "%s %s\n", res.Reference, res.Digest)
+		if res.Manifest.ArtifactType != "" {
+			fmt.Fprintf(w, "artifact type: %s\n", res.Manifest.ArtifactType)
+		}
+		keys := make([]string, 0, len(res.Manifest.Annotations))
+		for k := range res.Manifest.Annotations {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(w, "  %s=%s\n", k, res.Manifest.Annotations[k])
+		}
+		partitions := map[string]string{}
+		if res.Volume != nil {
+			for _, p := range res.Volume.Partitions {
+				partitions[p.ManifestRef] = p.Path
+			}
+		}
+		fmt.Fprintf(w, "layers (%d bytes):\n", res.LayerSize)
+		for _, l := range res.Manifest.Layers {
+			fmt.Fprintf(w, "  %s %10d %s\n", l.Digest, l.Size, partitions[l.Digest.String()])
+		}
+	})
+}
+
+func runList(env *cmdEnv, args []string) error {
+	fs := env.newFlagSet("list")
+	if err := env.parse(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := env.loadConfig()
+	if err != nil {
+		return err
+	}
+	artifacts, err := cfg.NewClient().ListLocal(context.Background())
+	if err != nil {
+		return err
+	}
+	return env.print(artifacts, func(w io.Writer) {
+		for _, a := range artifacts {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", a.Tag, a.Digest, a.Size, a.Partitions, a.CreatedAt)
+		}
+	})
+}
+
+func runUntag(env *cmdEnv, args []string) error {
+	fs := env.newFlagSet("untag")
+	var tag string
+	fs.StringVar(&tag, "tag", "", "tag to remove (required)")
+	if err := env.parse(fs, args); err != nil {
+		return err
+	}
+	if err := requireFlags("tag", tag); err != nil {
+		return err
+	}
+
+	cfg, err := env.loadConfig()
+	if err != nil {
+		return err
+	}
+	if err := cfg.NewClient().Untag(context.Background(), tag); err != nil {
+		return err
+	}
+	return env.print(map[string]string{"untagged": tag}, func(w io.Writer) {
+		fmt.Fprintf(w, "untagged %s\n", tag)
+	})
+}
+
+func runDelete(env *cmdEnv, args []string) error {
+	fs := env.newFlagSet("delete")
+	var ref string
+	fs.StringVar(&ref, "tag", "", "tag or manifest digest to delete (required)")
+	if err := env.parse(fs, args); err != nil {
+		return err
+	}
+	if err := requireFlags("tag", ref); err != nil {
+		return err
+	}
+
+	cfg, err := env.loadConfig()
+	if err != nil {
+		return err
+	}
+	if err := cfg.NewClient().Delete(context.Background(), ref); err != nil {
+		return err
+	}
+	return env.print(map[string]string{"deleted": ref}, func(w io.Writer) {
+		fmt.Fprintf(w, "deleted %s\n", ref)
+	})
+}
+
+func runVerify(env *cmdEnv, args []string) error {
+	fs := env.newFlagSet("verify")
+	var dest string
+	fs.StringVar(&dest, "dest", "", "directory a volume was fetched into (required)")
+	if err := env.parse(fs, args); err != nil {
+		return err
+	}
+	if err := requireFlags("dest", dest); err != nil {
+		return err
+	}
+
+	report, err := sori.NewClient().VerifyVolume(context.Background(), dest)
+	if err != nil {
+		return err
+	}
+	if err := env.print(report, func(w io.Writer) {
+		for _, p := range report.Missing {
+			fmt.Fprintf(w, "missing  %s\n", p)
+		}
+		for _, p := range report.Modified {
+			fmt.Fprintf(w, "modified %s\n", p)
+		}
+		for _, p := range report.Extra {
+			fmt.Fprintf(w, "extra    %s\n", p)
+		}
+		fmt.Fprintf(w, "%d files verified\n", report.Verified)
+	}); err != nil {
+		return err
+	}
+	if !report.OK() {
+		return &sori.Error{Kind: sori.KindIntegrity, Op: "verify", Message: fmt.Sprintf("%s does not match its file manifest", dest)}
+	}
+	return nil
+}
+
+func runDiff(env *cmdEnv, args []string) error {
+	fs := env.newFlagSet("diff")
+	var from, to, fromRemote, toRemote string
+	fs.StringVar(&from, "from", "", "older tag or manifest digest (required)")
+	fs.StringVar(&to, "to", "", "newer tag or manifest digest (required)")
+	fs.StringVar(&fromRemote, "from-remote", "", "read -from on this configured remote instead of the local store")
+	fs.StringVar(&toRemote, "to-remote", "", "read -to on this configured remote instead of the local store")
+	if err := env.parse(fs, args); err != nil {
+		return err
+	}
+	if err := requireFlags("from", from, "to", to); err != nil {
+		return err
+	}
+
+	cfg, err := env.loadConfig()
+	if err != nil {
+		return err
+	}
+	fromTarget, err := diffTarget(cfg, from, fromRemote)
+	if err != nil {
+		return err
+	}
+	toTarget, err := diffTarget(cfg, to, toRemote)
+	if err != nil {
+		return err
+	}
+	res, err := cfg.NewClient().DiffArtifacts(context.Background(), fromTarget, toTarget)
+	if err != nil {
+		return err
+	}
+	return env.print(res, func(w io.Writer) {
+		fmt.Fprintf(w, "from %s %s\n", res.From, res.FromDigest)
+		fmt.Fprintf(w, "to   %s %s\n", res.To, res.ToDigest)
+		for _, p := range res.Unchanged {
+			fmt.Fprintf(w, "  unchanged %s %s\n", p.Path, p.Digest)
+		}
+		for _, p := range res.Partitions {
+			switch p.Change {
+			case sori.ChangeAdded:
+				fmt.Fprintf(w, "  added     %s %s\n", p.Path, p.NewDigest)
+			case sori.ChangeRemoved:
+				fmt.Fprintf(w, "  removed   %s %s\n", p.Path, p.OldDigest)
+			default:
+				fmt.Fprintf(w, "  modified  %s %s -> %s\n", p.Path, p.OldDigest, p.NewDigest)
+			}
+			for _, f := range p.Files {
+				fmt.Fprintf(w, "      %-8s %s\n", f.Change, f.Path)
+			}
+		}
+	})
+}
+
+// diffTarget builds one side of a diff, on the named remote when remoteName
+// is set.
+func diffTarget(cfg *sori.Config, ref, remoteName string) (sori.DiffTarget, error) {
+	if remoteName == "" {
+		return sori.DiffTarget{Ref: ref}, nil
+	}
+	target, err := cfg.RemoteTarget(remoteName)
+	if err != nil {
+		return sori.DiffTarget{}, err
+	}
+	return sori.DiffTarget{Ref: ref, Remote: &target}, nil
+}
+
+func runGC(env *cmdEnv, args []string) error {
+	fs := env.newFlagSet("gc")
+	var opts sori.GCOptions
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "report reclaimable blobs without deleting them")
+	if err := env.parse(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := env.loadConfig()
+	if err != nil {
+		return err
+	}
+	res, err := cfg.NewClient().GarbageCollect(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+	return env.print(res, func(w io.Writer) {
+		verb := "removed"
+		if res.DryRun {
+			verb = "would remove"
+		}
+		fmt.Fprintf(w, "%s %d blobs (%d bytes), kept %d\n", verb, len(res.RemovedBlobs), res.ReclaimedBytes, res.ReachableBlobs)
+		if res.RemovedTempFiles > 0 {
+			fmt.Fprintf(w, "%s %d stale temporary files\n", verb, res.RemovedTempFiles)
+		}
+	})
+}
@@ -0,0 +1,208 @@
+// Command sori packages, pushes, fetches, and inspects reference datasets
+// stored as OCI artifacts.
+//
+// Usage:
+//
+//	sori <command> [flags]
+//
+// Commands read the local store and remotes from sori-oci.json (see -config)
+// and print human-readable output, or JSON with -json. Failures exit with a
+// code derived from the sori.Error kind; see exitCode.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/seoyhaein/sori"
+)
+
+// Exit codes. Every sori.ErrorKind has its own code so scripts can tell a
+// missing tag from a registry outage without parsing messages.
+const (
+	exitOK         = 0
+	exitFailure    = 1
+	exitUsage      = 2
+	exitValidation = 3
+	exitNotFound   = 4
+	exitConflict   = 5
+	exitIntegrity  = 6
+	exitTransport  = 7
+	exitAuth       = 8
+)
+
+const defaultConfigPath = "sori-oci.json"
+
+type command struct {
+	name    string
+	summary string
+	run     func(env *cmdEnv, args []string) error
+}
+
+var commands = []command{
+	{"package", "package a directory into the local OCI store", runPackage},
+	{"push", "push a packaged tag to a configured remote", runPush},
+	{"fetch", "restore a packaged tag into a directory", runFetch},
+	{"verify", "check a fetched volume against its file manifest", runVerify},
+	{"inspect", "show the manifest of a packaged tag", runInspect},
+	{"diff", "compare the partitions and files of two packaged tags", runDiff},
+	{"list", "list tags in the local OCI store", runList},
+	{"untag", "remove a tag from the local OCI store", runUntag},
+	{"delete", "delete a tagged artifact from the local OCI store", runDelete},
+	{"gc", "remove unreachable blobs from the local OCI store", runGC},
+}
+
+// cmdEnv carries the output streams and flags shared by every command.
+type cmdEnv struct {
+	stdout     io.Writer
+	stderr     io.Writer
+	configPath string
+	jsonOutput bool
+}
+
+// usageError marks bad command-line input, which exits with exitUsage.
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string { return e.msg }
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	sori.Log.SetOutput(stderr)
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		printUsage(stderr)
+		if len(args) == 0 {
+			return exitUsage
+		}
+		return exitOK
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		env := &cmdEnv{stdout: stdout, stderr: stderr}
+		err := cmd.run(env, args[1:])
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		if err != nil {
+			reportError(env, err)
+			return exitCode(err)
+		}
+		return exitOK
+	}
+
+	fmt.Fprintf(stderr, "sori: unknown command %q\n\n", args[0])
+	printUsage(stderr)
+	return exitUsage
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: sori <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `run "sori <command> -h" for command flags`)
+}
+
+// newFlagSet returns a flag set with the flags every command accepts.
+func (env *cmdEnv) newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("sori "+name, flag.ContinueOnError)
+	fs.SetOutput(env.stderr)
+	fs.StringVar(&env.configPath, "config", defaultConfigPath, "path to sori-oci.json")
+	fs.BoolVar(&env.jsonOutput, "json", false, "print JSON output")
+	return fs
+}
+
+// parse parses args and rejects stray positional arguments.
+func (env *cmdEnv) parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return &usageError{msg: err.Error()}
+	}
+	if fs.NArg() > 0 {
+		return &usageError{msg: fmt.Sprintf("unexpected argument %q", fs.Arg(0))}
+	}
+	return nil
+}
+
+func (env *cmdEnv) loadConfig() (*sori.Config, error) {
+	return sori.LoadConfig(env.configPath)
+}
+
+// print writes v as indented JSON with -json, or calls text otherwise.
+func (env *cmdEnv) print(v any, text func(w io.Writer)) error {
+	if env.jsonOutput {
+		enc := json.NewEncoder(env.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	text(env.stdout)
+	return nil
+}
+
+func reportError(env *cmdEnv, err error) {
+	if !env.jsonOutput {
+		fmt.Fprintf(env.stderr, "sori: %v\n", err)
+		return
+	}
+	out := struct {
+		Error string `json:"error"`
+		Kind  string `json:"kind,omitempty"`
+		Op    string `json:"op,omitempty"`
+		// Status and Code are set for failed registry responses.
+		Status int    `json:"status,omitempty"`
+		Code   string `json:"code,omitempty"`
+	}{Error: err.Error()}
+	var serr *sori.Error
+	if errors.As(err, &serr) {
+		out.Kind = string(serr.Kind)
+		out.Op = serr.Op
+		out.Status = serr.StatusCode
+		out.Code = serr.Code
+	}
+	_ = json.NewEncoder(env.stderr).Encode(out)
+}
+
+// exitCode maps an error to the process exit code using the outermost
+// sori.Error kind.
+func exitCode(err error) int {
+	var uerr *usageError
+	if errors.As(err, &uerr) {
+		return exitUsage
+	}
+	var serr *sori.Error
+	if !errors.As(err, &serr) {
+		return exitFailure
+	}
+	switch serr.Kind {
+	case sori.KindValidation:
+		return exitValidation
+	case sori.KindNotFound:
+		return exitNotFound
+	case sori.KindConflict:
+		return exitConflict
+	case sori.KindIntegrity:
+		return exitIntegrity
+	case sori.KindTransport:
+		return exitTransport
+	case sori.KindAuth:
+		return exitAuth
+	default:
+		return exitFailure
+	}
+}
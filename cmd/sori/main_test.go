@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seoyhaein/sori"
+)
+
+func writeTestConfig(t *testing.T) (configPath, storePath string) {
+	t.Helper()
+	dir := t.TempDir()
+	storePath = filepath.Join(dir, "oci")
+	cfg := fmt.Sprintf(`{"local":{"type":"oci","path":%q},"remotes":[]}`, storePath)
+	configPath = filepath.Join(dir, "sori-oci.json")
+	if err := os.WriteFile(configPath, []byte(cfg), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return configPath, storePath
+}
+
+func writeTestVolume(t *testing.T) string {
+	t.Helper()
+	src := filepath.Join(t.TempDir(), "vol")
+	for rel, data := range map[string]string{
+		"configblob.json": "{}",
+		"a/a.txt":         "a",
+		"b/b.txt":         "b",
+	} {
+		path := filepath.Join(src, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("MkdirAll: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	return src
+}
+
+func runCLI(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun_PackageListInspectFetch(t *testing.T) {
+	configPath, _ := writeTestConfig(t)
+	src := writeTestVolume(t)
+
+	code, out, errOut := runCLI(t, "package", "-config", configPath, "-json",
+		"-src", src, "-name", "CLI Volume", "-tag", "cli.v1", "-annotation", "env=test")
+	if code != exitOK {
+		t.Fatalf("package exit %d: %s", code, errOut)
+	}
+	var pkg sori.PackageResult
+	if err := json.Unmarshal([]byte(out), &pkg); err != nil {
+		t.Fatalf("decode package output: %v\n%s", err, out)
+	}
+	if pkg.LocalTag != "cli.v1" || pkg.ManifestDigest == "" {
+		t.Fatalf("unexpected package result: %+v", pkg)
+	}
+
+	code, out, errOut = runCLI(t, "list", "-config", configPath, "-json")
+	if code != exitOK {
+		t.Fatalf("list exit %d: %s", code, errOut)
+	}
+	var entries []sori.LocalArtifact
+	if err := json.Unmarshal([]byte(out), &entries); err != nil {
+		t.Fatalf("decode list output: %v\n%s", err, out)
+	}
+	if len(entries) != 1 || entries[0].Tag != "cli.v1" || entries[0].Digest != pkg.ManifestDigest || entries[0].Partitions != len(pkg.Partitions) {
+		t.Fatalf("unexpected list output: %+v", entries)
+	}
+
+	code, out, errOut = runCLI(t, "inspect", "-config", configPath, "-json", "-tag", "cli.v1")
+	if code != exitOK {
+		t.Fatalf("inspect exit %d: %s", code, errOut)
+	}
+	var inspected sori.Inspection
+	if err := json.Unmarshal([]byte(out), &inspected); err != nil {
+		t.Fatalf("decode inspect output: %v\n%s", err, out)
+	}
+	if inspected.Digest != pkg.ManifestDigest || inspected.Volume == nil || len(inspected.Volume.Partitions) != len(pkg.Partitions) {
+		t.Fatalf("unexpected inspect output: %+v", inspected)
+	}
+	if inspected.Manifest.Annotations["env"] != "test" {
+		t.Fatalf("expected env annotation, got %v", inspected.Manifest.Annotations)
+	}
+
+	code, out, errOut = runCLI(t, "gc", "-config", configPath, "-json", "-dry-run")
+	if code != exitOK {
+		t.Fatalf("gc exit %d: %s", code, errOut)
+	}
+	var gc sori.GCResult
+	if err := json.Unmarshal([]byte(out), &gc); err != nil {
+		t.Fatalf("decode gc output: %v\n%s", err, out)
+	}
+	if !gc.DryRun || len(gc.RemovedBlobs) != 0 || gc.ReachableBlobs == 0 {
+		t.Fatalf("unexpected gc output: %+v", gc)
+	}
+
+	dest := filepath.Join(t.TempDir(), "restored")
+	code, _, errOut = runCLI(t, "fetch", "-config", configPath, "-tag", "cli.v1", "-dest", dest)
+	if code != exitOK {
+		t.Fatalf("fetch exit %d: %s", code, errOut)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "vol", "a", "a.txt")); err != nil {
+		t.Fatalf("expected restored file: %v", err)
+	}
+
+	if code, _, errOut = runCLI(t, "verify", "-dest", dest); code != exitOK {
+		t.Fatalf("verify exit %d: %s", code, errOut)
+	}
+	if err := os.WriteFile(filepath.Join(dest, "vol", "a", "a.txt"), []byte("changed"), 0o644); err != nil {
+		t.Fatalf("modify restored file: %v", err)
+	}
+	if code, _, errOut = runCLI(t, "verify", "-dest", dest); code != exitIntegrity {
+		t.Fatalf("verify after modification: exit %d: %s", code, errOut)
+	}
+
+	if err := os.WriteFile(filepath.Join(src, "a", "a.txt"), []byte("changed"), 0o644); err != nil {
+		t.Fatalf("modify source file: %v", err)
+	}
+	if code, _, errOut = runCLI(t, "package", "-config", configPath, "-src", src, "-name", "CLI Volume", "-tag", "cli.v2"); code != exitOK {
+		t.Fatalf("package v2 exit %d: %s", code, errOut)
+	}
+	code, out, errOut = runCLI(t, "diff", "-config", configPath, "-json", "-from", "cli.v1", "-to", "cli.v2")
+	if code != exitOK {
+		t.Fatalf("diff exit %d: %s", code, errOut)
+	}
+	var diff sori.ArtifactDiff
+	if err := json.Unmarshal([]byte(out), &diff); err != nil {
+		t.Fatalf("decode diff output: %v\n%s", err, out)
+	}
+	if !diff.Changed || len(diff.Files) != 1 || diff.Files[0].Path != "vol/a/a.txt" || diff.Files[0].Change != sori.ChangeModified {
+		t.Fatalf("unexpected diff output: %+v", diff)
+	}
+
+	if code, _, errOut = runCLI(t, "untag", "-config", configPath, "-tag", "cli.v1"); code != exitOK {
+		t.Fatalf("untag exit %d: %s", code, errOut)
+	}
+	if code, _, errOut = runCLI(t, "inspect", "-config", configPath, "-tag", "cli.v1"); code != exitNotFound {
+		t.Fatalf("inspect after untag: exit %d: %s", code, errOut)
+	}
+	if code, _, errOut = runCLI(t, "delete", "-config", configPath, "-tag", pkg.ManifestDigest); code != exitOK {
+		t.Fatalf("delete exit %d: %s", code, errOut)
+	}
+}
+
+func TestRun_ExitCodes(t *testing.T) {
+	configPath, _ := writeTestConfig(t)
+	src := writeTestVolume(t)
+	if code, _, errOut := runCLI(t, "package", "-config", configPath, "-src", src, "-name", "v", "-tag", "v1"); code != exitOK {
+		t.Fatalf("package exit %d: %s", code, errOut)
+	}
+
+	cases := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"no command", nil, exitUsage},
+		{"unknown command", []string{"frobnicate"}, exitUsage},
+		{"missing flag", []string{"fetch", "-config", configPath, "-tag", "v1"}, exitUsage},
+		{"missing config", []string{"list", "-config", filepath.Join(t.TempDir(), "none.json")}, exitNotFound},
+		{"missing tag", []string{"inspect", "-config", configPath, "-tag", "nope"}, exitNotFound},
+		{"unknown remote", []string{"push", "-config", configPath, "-remote", "nope", "-tag", "v1"}, exitNotFound},
+		{"bad compression", []string{"package", "-config", configPath, "-src", src, "-name", "v", "-tag", "v2", "-compression", "lz4"}, exitValidation},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if code, _, errOut := runCLI(t, tc.args...); code != tc.want {
+				t.Fatalf("exit code: got %d want %d (%s)", code, tc.want, errOut)
+			}
+		})
+	}
+}
+
+func TestExitCode_Kinds(t *testing.T) {
+	wrapped := fmt.Errorf("outer: %w", &sori.Error{Kind: sori.KindAuth, Op: "push"})
+	if got := exitCode(wrapped); got != exitAuth {
+		t.Fatalf("auth: got %d want %d", got, exitAuth)
+	}
+	if got := exitCode(errors.New("plain")); got != exitFailure {
+		t.Fatalf("plain error: got %d want %d", got, exitFailure)
+	}
+}
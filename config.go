@@ -7,6 +7,9 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
+
+	"github.com/seoyhaein/sori/registryutil"
 )
 
 type (
@@ -23,6 +26,7 @@ type (
 		Type       string     `json:"type"`       // "registry"
 		Registry   string     `json:"registry"`   // e.g. harbor.local
 		Repository string     `json:"repository"` // e.g. harbor 인 경우 project/repo
+		PlainHTTP  bool       `json:"plain_http,omitempty"`
 		TLS        TLSConfig  `json:"tls"`
 		Auth       AuthConfig `json:"auth"`
 	}
@@ -34,12 +38,26 @@ type (
 		Username string `json:"username"`
 		Password string `json:"password"`
 		Token    string `json:"token"`
+		// PasswordFile and TokenFile read the secret from a file, such as a
+		// mounted Kubernetes secret. Relative paths are resolved against the
+		// config file's directory.
+		PasswordFile string `json:"password_file,omitempty"`
+		TokenFile    string `json:"token_file,omitempty"`
+		// CredentialStore, when set, looks credentials up outside the config
+		// instead of using the static fields above: "docker" (Docker/Podman
+		// auth files and their credential helpers), "file" (ConfigFile), or
+		// "helper" (docker-credential-<Helper>).
+		CredentialStore string `json:"credential_store,omitempty"`
+		ConfigFile      string `json:"config_file,omitempty"`
+		Helper          string `json:"helper,omitempty"`
 	}
 )
 
 const (
 	defaultDirPerm  fs.FileMode = 0o755
 	defaultOCIStore             = "/var/lib/sori/oci"
+	// remoteTypeRegistry is the only RemoteStore type RemoteTarget accepts.
+	remoteTypeRegistry = "registry"
 )
 
 // Deprecated: use LoadConfig followed by Config.NewClient so new code stays on
@@ -54,9 +72,11 @@ func InitConfig(path string) (*Config, error) {
 }
 
 // NewClient constructs the preferred core client path from configuration.
+// The client keeps conf so Client.PushToRemote can resolve remotes by name.
 func (conf *Config) NewClient(opts ...ClientOption) *Client {
-	allOpts := make([]ClientOption, 0, len(opts)+1)
+	allOpts := make([]ClientOption, 0, len(opts)+2)
 	allOpts = append(allOpts, WithLocalStorePath(conf.Local.Path))
+	allOpts = append(allOpts, func(c *Client) { c.config = conf })
 	allOpts = append(allOpts, opts...)
 	return NewClient(allOpts...)
 }
@@ -100,6 +120,10 @@ func LoadConfig(path string) (*Config, error) {
 	if err := json.NewDecoder(f).Decode(&cfg); err != nil {
 		return nil, validationError("LoadConfig", "decode json", err)
 	}
+	if err := cfg.loadSecretFiles(filepath.Dir(abs)); err != nil {
+		return nil, err
+	}
+	cfg.applyEnvOverrides(os.LookupEnv)
 
 	// 유효성 검사 TODO 지금 이렇게 간단히 하지만, 별도의 메서드를 만들어서 configblob 도 확인해줘야 함.
 	if cfg.Local.Path == "" {
@@ -118,6 +142,139 @@ func LoadConfig(path string) (*Config, error) {
 	return &cfg, nil
 }
 
+// Environment variables read by LoadConfig. SORI_LOCAL_PATH replaces
+// local.path; SORI_REMOTE_<NAME>_USERNAME, _PASSWORD, and _TOKEN replace a
+// remote's credentials, where <NAME> is the remote name upper-cased with
+// every character other than A-Z and 0-9 replaced by "_" (remote
+// "harbor-prod" reads SORI_REMOTE_HARBOR_PROD_PASSWORD).
+const (
+	envLocalPath      = "SORI_LOCAL_PATH"
+	envRemotePrefix   = "SORI_REMOTE_"
+	envUsernameSuffix = "_USERNAME"
+	envPasswordSuffix = "_PASSWORD"
+	envTokenSuffix    = "_TOKEN"
+)
+
+// loadSecretFiles fills Password and Token from password_file and token_file.
+func (conf *Config) loadSecretFiles(baseDir string) error {
+	for i := range conf.Remotes {
+		a := &conf.Remotes[i].Auth
+		if a.PasswordFile != "" {
+			if a.Password != "" {
+				return validationError("LoadConfig", fmt.Sprintf("remotes[%d] sets both password and password_file", i), nil)
+			}
+			secret, err := readSecretFile(baseDir, a.PasswordFile)
+			if err != nil {
+				return err
+			}
+			a.Password = secret
+		}
+		if a.TokenFile != "" {
+			if a.Token != "" {
+				return validationError("LoadConfig", fmt.Sprintf("remotes[%d] sets both token and token_file", i), nil)
+			}
+			secret, err := readSecretFile(baseDir, a.TokenFile)
+			if err != nil {
+				return err
+			}
+			a.Token = secret
+		}
+	}
+	return nil
+}
+
+func readSecretFile(baseDir, path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", notFoundError("LoadConfig", fmt.Sprintf("secret file not found: %s", path), err)
+		}
+		return "", transportError("LoadConfig", fmt.Sprintf("read secret file %s", path), err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// applyEnvOverrides replaces the local store path and remote credentials with
+// any SORI_* environment variables that are set.
+func (conf *Config) applyEnvOverrides(lookup func(string) (string, bool)) {
+	if v, ok := lookup(envLocalPath); ok {
+		conf.Local.Path = v
+	}
+	for i := range conf.Remotes {
+		r := &conf.Remotes[i]
+		prefix := envRemotePrefix + envName(r.Name)
+		if v, ok := lookup(prefix + envUsernameSuffix); ok {
+			r.Auth.Username = v
+		}
+		if v, ok := lookup(prefix + envPasswordSuffix); ok {
+			r.Auth.Password = v
+		}
+		if v, ok := lookup(prefix + envTokenSuffix); ok {
+			r.Auth.Token = v
+		}
+	}
+}
+
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// RemoteTarget builds the RemoteTarget for the remote named name, carrying its
+// TLS settings, CA file, and credentials. It fails with ErrNotFound when no
+// remote has that name and with ErrValidation when its type is not
+// "registry".
+func (conf *Config) RemoteTarget(name string) (RemoteTarget, error) {
+	if conf == nil {
+		return RemoteTarget{}, validationError("Config.RemoteTarget", "config is nil", nil)
+	}
+	for _, r := range conf.Remotes {
+		if r.Name != name {
+			continue
+		}
+		if r.Type != remoteTypeRegistry {
+			return RemoteTarget{}, validationError("Config.RemoteTarget", fmt.Sprintf("remote %q has type %q, want %q", name, r.Type, remoteTypeRegistry), nil)
+		}
+		target := RemoteTarget{
+			Registry:    r.Registry,
+			Repository:  r.Repository,
+			PlainHTTP:   r.PlainHTTP,
+			InsecureTLS: r.TLS.Insecure,
+			CAFile:      r.TLS.CAFile,
+			Username:    r.Auth.Username,
+			Password:    r.Auth.Password,
+			Token:       r.Auth.Token,
+		}
+		if r.Auth.CredentialStore != "" {
+			if r.Auth.Username != "" || r.Auth.Password != "" || r.Auth.Token != "" {
+				return RemoteTarget{}, validationError("Config.RemoteTarget", fmt.Sprintf("remote %q sets both credential_store and static credentials", name), nil)
+			}
+			provider, err := registryutil.NewCredentialFunc(registryutil.CredentialSource{
+				Store:      r.Auth.CredentialStore,
+				ConfigFile: r.Auth.ConfigFile,
+				Helper:     r.Auth.Helper,
+			})
+			if err != nil {
+				return RemoteTarget{}, validationError("Config.RemoteTarget", fmt.Sprintf("remote %q credential store", name), err)
+			}
+			target.AuthProvider = provider
+		}
+		return target, nil
+	}
+	return RemoteTarget{}, notFoundError("Config.RemoteTarget", fmt.Sprintf("remote %q is not configured", name), nil)
+}
+
 // EnsureDir sori-oci.json 에 있는 path 에 실제 디렉토리가 있는지, TODO 수정해줘야 함. 루트 권한의 폴더에 대해서는 에러 리턴함. 오류는 아님.
 func (conf *Config) EnsureDir() error {
 	// 방어적 코드
@@ -93,3 +93,119 @@ func TestLoadConfig_NotFoundTypedError(t *testing.T) {
 // TODO 여기서 테스트 몇가지 더 진행해야 한다.
 // TODO configblob.json 에 대해서도 처리 해줘야 한다. 볼륨 만들어줘야 하는 폴더에 있어야 한다. 그래야 oci 에 저장할 수 있음.
 // TODO 파일 읽기 다양하게 하는데 표준정해 놓고, 가장 좋은 것을 선택하자. 일단 여기서 부터 시작하자.
+
+func TestConfigRemoteTarget(t *testing.T) {
+	cfg := &Config{
+		Local: LocalStore{Type: "oci", Path: t.TempDir()},
+		Remotes: []RemoteStore{
+			{
+				Name:       "harbor",
+				Type:       "registry",
+				Registry:   "harbor.local",
+				Repository: "project/repo",
+				TLS:        TLSConfig{Insecure: true, CAFile: "/etc/ssl/harbor.pem"},
+				Auth:       AuthConfig{Username: "admin", Password: "pw", Token: "tok"},
+			},
+			{Name: "bucket", Type: "s3", Registry: "s3.local", Repository: "b"},
+		},
+	}
+
+	target, err := cfg.RemoteTarget("harbor")
+	if err != nil {
+		t.Fatalf("RemoteTarget: %v", err)
+	}
+	want := RemoteTarget{
+		Registry:    "harbor.local",
+		Repository:  "project/repo",
+		InsecureTLS: true,
+		CAFile:      "/etc/ssl/harbor.pem",
+		Username:    "admin",
+		Password:    "pw",
+		Token:       "tok",
+	}
+	if target.Registry != want.Registry || target.Repository != want.Repository ||
+		target.InsecureTLS != want.InsecureTLS || target.CAFile != want.CAFile ||
+		target.Username != want.Username || target.Password != want.Password || target.Token != want.Token {
+		t.Fatalf("RemoteTarget mismatch:\n got %+v\nwant %+v", target, want)
+	}
+
+	if _, err := cfg.RemoteTarget("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := cfg.RemoteTarget("bucket"); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected ErrValidation for non-registry remote, got %v", err)
+	}
+}
+
+func TestLoadConfig_SecretFilesAndEnvOverrides(t *testing.T) {
+	tmp := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmp, "harbor-password"), []byte("from-file\n"), 0o600); err != nil {
+		t.Fatalf("write password file: %v", err)
+	}
+	tokenFile := filepath.Join(tmp, "token")
+	if err := os.WriteFile(tokenFile, []byte("tok-from-file"), 0o600); err != nil {
+		t.Fatalf("write token file: %v", err)
+	}
+	cfgJSON := `{
+		"local": {"type": "oci", "path": "/var/lib/sori/oci"},
+		"remotes": [
+			{"name": "harbor-prod", "type": "registry", "registry": "harbor.local", "repository": "p/r",
+			 "auth": {"username": "admin", "password_file": "harbor-password"}},
+			{"name": "ghcr", "type": "registry", "registry": "ghcr.io", "repository": "o/r",
+			 "auth": {"token_file": "` + filepath.ToSlash(tokenFile) + `"}}
+		]
+	}`
+	cfgPath := filepath.Join(tmp, "sori-oci.json")
+	if err := os.WriteFile(cfgPath, []byte(cfgJSON), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	conf, err := LoadConfig(cfgPath)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if got := conf.Remotes[0].Auth.Password; got != "from-file" {
+		t.Fatalf("password from file: got %q", got)
+	}
+	if got := conf.Remotes[1].Auth.Token; got != "tok-from-file" {
+		t.Fatalf("token from file: got %q", got)
+	}
+
+	storePath := filepath.Join(tmp, "oci")
+	t.Setenv("SORI_LOCAL_PATH", storePath)
+	t.Setenv("SORI_REMOTE_HARBOR_PROD_USERNAME", "robot")
+	t.Setenv("SORI_REMOTE_HARBOR_PROD_PASSWORD", "from-env")
+	t.Setenv("SORI_REMOTE_GHCR_TOKEN", "tok-from-env")
+	conf, err = LoadConfig(cfgPath)
+	if err != nil {
+		t.Fatalf("LoadConfig with env: %v", err)
+	}
+	if conf.Local.Path != storePath {
+		t.Fatalf("local path override: got %q want %q", conf.Local.Path, storePath)
+	}
+	if a := conf.Remotes[0].Auth; a.Username != "robot" || a.Password != "from-env" {
+		t.Fatalf("harbor-prod env override: got %+v", a)
+	}
+	if got := conf.Remotes[1].Auth.Token; got != "tok-from-env" {
+		t.Fatalf("ghcr token env override: got %q", got)
+	}
+}
+
+func TestLoadConfig_SecretFileErrors(t *testing.T) {
+	tmp := t.TempDir()
+	write := func(auth string) string {
+		path := filepath.Join(tmp, "cfg.json")
+		cfgJSON := `{"local":{"type":"oci","path":"/tmp/oci"},"remotes":[{"name":"r","type":"registry","registry":"reg","repository":"p/r","auth":` + auth + `}]}`
+		if err := os.WriteFile(path, []byte(cfgJSON), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		return path
+	}
+
+	if _, err := LoadConfig(write(`{"password_file":"missing"}`)); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for missing secret file, got %v", err)
+	}
+	if _, err := LoadConfig(write(`{"password":"x","password_file":"missing"}`)); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected ErrValidation for password and password_file, got %v", err)
+	}
+}
@@ -3,6 +3,10 @@ package sori
 import (
 	"errors"
 	"fmt"
+	"net/http"
+
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry/remote/errcode"
 )
 
 // ErrorKind classifies exported core errors returned by the root package.
@@ -23,6 +27,11 @@ type Error struct {
 	Op      string
 	Message string
 	Err     error
+	// StatusCode is the HTTP status of a failed registry response, or zero.
+	StatusCode int
+	// Code is the first distribution error code in a failed registry
+	// response, such as "UNAUTHORIZED" or "MANIFEST_UNKNOWN", or empty.
+	Code string
 }
 
 func (e *Error) Error() string {
@@ -104,6 +113,39 @@ func authError(op, message string, err error) error {
 	return newError(KindAuth, op, message, err)
 }
 
+// registryError classifies an error returned by a registry or OCI store call.
+// Registry error responses keep their HTTP status and error code: 401 and 403
+// become KindAuth, 404 KindNotFound, 409 KindConflict, and anything else
+// KindTransport. Store-level not-found and already-exists errors map to
+// KindNotFound and KindConflict.
+func registryError(op, message string, err error) error {
+	var resp *errcode.ErrorResponse
+	if errors.As(err, &resp) {
+		e := &Error{Kind: KindTransport, Op: op, Message: message, Err: err, StatusCode: resp.StatusCode}
+		if len(resp.Errors) > 0 {
+			e.Code = resp.Errors[0].Code
+		}
+		switch {
+		case resp.StatusCode == http.StatusUnauthorized, resp.StatusCode == http.StatusForbidden,
+			e.Code == errcode.ErrorCodeUnauthorized, e.Code == errcode.ErrorCodeDenied:
+			e.Kind = KindAuth
+		case resp.StatusCode == http.StatusNotFound:
+			e.Kind = KindNotFound
+		case resp.StatusCode == http.StatusConflict:
+			e.Kind = KindConflict
+		}
+		return e
+	}
+	switch {
+	case errors.Is(err, errdef.ErrNotFound):
+		return notFoundError(op, message, err)
+	case errors.Is(err, errdef.ErrAlreadyExists):
+		return conflictError(op, message, err)
+	default:
+		return transportError(op, message, err)
+	}
+}
+
 func isKind(err error, target error) bool {
 	return errors.Is(err, target)
 }
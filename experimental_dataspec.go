@@ -3,8 +3,6 @@ package sori
 import (
 	"context"
 	"strings"
-
-	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
 const DataSpecMediaType = "application/vnd.nodevault.dataspec.v1+json"
@@ -118,7 +116,7 @@ func ArtifactMetadataToDataSpec(meta *ArtifactMetadata) *DataSpec {
 			Version:   meta.Identity.Version,
 		},
 		Data: DataSection{
-			ArtifactType:   ocispec.MediaTypeImageManifest,
+			ArtifactType:   ArtifactTypeVolume,
 			Repository:     meta.Location.Repository,
 			Reference:      meta.Location.Reference,
 			ManifestDigest: meta.Location.ManifestDigest,
@@ -164,7 +162,7 @@ func PushRemoteDataSpecReferrer(ctx context.Context, push *PushResult, target Re
 	}
 	subjectDesc, err := repo.Resolve(ctx, push.ManifestDigest)
 	if err != nil {
-		return nil, notFoundError("PushRemoteDataSpecReferrer", "resolve subject manifest", err)
+		return nil, registryError("PushRemoteDataSpecReferrer", "resolve subject manifest", err)
 	}
 
 	result, err := pushDataSpecManifest(ctx, repo, subjectDesc, spec)
@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"strings"
 
 	"github.com/seoyhaein/sori/registryutil"
@@ -16,6 +17,7 @@ import (
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	orasoras "oras.land/oras-go/v2"
 	"oras.land/oras-go/v2/content/oci"
+	"oras.land/oras-go/v2/errdef"
 	"oras.land/oras-go/v2/registry/remote/auth"
 )
 
@@ -126,13 +128,22 @@ func pushSpecReferrer(
 		Size:      int64(len(specJSON)),
 	}
 	if err := pushBlobIfAbsent(ctx, target, configDesc, specJSON); err != nil {
-		return SpecReferrerResult{}, transportError("pushSpecReferrer", "push config blob", err)
+		return SpecReferrerResult{}, registryError("pushSpecReferrer", "push config blob", err)
 	}
 
-	subjectDesc := ocispec.Descriptor{
-		MediaType: ocispec.MediaTypeImageManifest,
-		Digest:    godigest.Digest(subjectDigest),
+	// The subject descriptor needs its real size: an OCI layout whose
+	// referrer records size 0 fails to load again. Fall back to a bare
+	// descriptor only when the subject is not in the target.
+	subjectDesc, err := target.Resolve(ctx, subjectDigest)
+	if errors.Is(err, errdef.ErrNotFound) {
+		subjectDesc = ocispec.Descriptor{
+			MediaType: ocispec.MediaTypeImageManifest,
+			Digest:    godigest.Digest(subjectDigest),
+		}
+	} else if err != nil {
+		return SpecReferrerResult{}, registryError("pushSpecReferrer", "resolve subject "+subjectDigest, err)
 	}
+	subjectDesc = ocispec.Descriptor{MediaType: subjectDesc.MediaType, Digest: subjectDesc.Digest, Size: subjectDesc.Size}
 	manifestDesc, err := orasoras.PackManifest(
 		ctx, target,
 		orasoras.PackManifestVersion1_1,
@@ -144,7 +155,7 @@ func pushSpecReferrer(
 		},
 	)
 	if err != nil {
-		return SpecReferrerResult{}, transportError("pushSpecReferrer", "pack referrer manifest", err)
+		return SpecReferrerResult{}, registryError("pushSpecReferrer", "pack referrer manifest", err)
 	}
 
 	return SpecReferrerResult{
@@ -0,0 +1,28 @@
+package sori
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestVolume writes a small volume with a config blob and the partitions
+// a and b into src. payload is the content of a/a.txt, so tests can change one
+// partition while b stays the same.
+func writeTestVolume(t *testing.T, src, payload string) {
+	t.Helper()
+	writeTestFile(t, filepath.Join(src, ConfigBlobJson), "{}")
+	writeTestFile(t, filepath.Join(src, "a", "a.txt"), payload)
+	writeTestFile(t, filepath.Join(src, "b", "b.txt"), "stable")
+}
+
+// writeTestFile writes data to path, creating its parent directories.
+func writeTestFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package sori
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+)
+
+// Inspection is the decoded view of one artifact, read from its manifest and
+// config blob without fetching any layer.
+type Inspection struct {
+	Reference string `json:"reference"`
+	Digest    string `json:"digest"`
+	// Size is the manifest, config, and layer bytes the artifact references.
+	Size int64 `json:"size"`
+	// LayerSize is the sum of the layer sizes, the bytes a fetch downloads.
+	LayerSize int64            `json:"layer_size"`
+	Manifest  ocispec.Manifest `json:"manifest"`
+	// Config is the config blob when it is JSON, and empty otherwise.
+	Config json.RawMessage `json:"config,omitempty"`
+	// Volume is the partition layout recorded in the manifest, or nil when
+	// the artifact is not a sori volume.
+	Volume *VolumeIndex `json:"volume,omitempty"`
+}
+
+// InspectRemote resolves ref in the remote repository described by target and
+// returns its manifest, config blob, and partition layout. Only the manifest
+// and config blob are downloaded.
+func (c *Client) InspectRemote(ctx context.Context, target RemoteTarget, ref string) (*Inspection, error) {
+	const op = "Client.InspectRemote"
+	if c.httpClient != nil {
+		target.HTTPClient = c.httpClient
+	}
+	repo, _, err := openRemoteFetchRepository(op, target, ref)
+	if err != nil {
+		return nil, err
+	}
+	return inspectArtifact(ctx, op, repo, ref)
+}
+
+// inspectArtifact builds an Inspection of ref in src.
+func inspectArtifact(ctx context.Context, op string, src oras.ReadOnlyTarget, ref string) (*Inspection, error) {
+	desc, manifest, err := fetchManifest(ctx, op, src, ref)
+	if err != nil {
+		return nil, err
+	}
+	res := &Inspection{
+		Reference: ref,
+		Digest:    desc.Digest.String(),
+		Size:      manifestTotalSize(desc, manifest),
+		Manifest:  manifest,
+	}
+	for _, layer := range manifest.Layers {
+		res.LayerSize += layer.Size
+	}
+	if manifest.Config.Size > 0 {
+		config, err := content.FetchAll(ctx, src, manifest.Config)
+		if err != nil {
+			return nil, registryError(op, fmt.Sprintf("fetch config %s", manifest.Config.Digest), err)
+		}
+		if json.Valid(config) {
+			res.Config = config
+		}
+	}
+	if isVolumeManifest(manifest) {
+		res.Volume = volumeIndexFromManifest(desc, manifest)
+	}
+	return res, nil
+}
+
+// fetchManifest resolves ref in src and decodes the image manifest.
+func fetchManifest(ctx context.Context, op string, src oras.ReadOnlyTarget, ref string) (ocispec.Descriptor, ocispec.Manifest, error) {
+	var manifest ocispec.Manifest
+	desc, err := src.Resolve(ctx, ref)
+	if err != nil {
+		return desc, manifest, registryError(op, fmt.Sprintf("resolve %q", ref), err)
+	}
+	data, err := content.FetchAll(ctx, src, desc)
+	if err != nil {
+		return desc, manifest, registryError(op, fmt.Sprintf("fetch manifest %s", desc.Digest), err)
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return desc, manifest, integrityError(op, fmt.Sprintf("decode manifest %s", desc.Digest), err)
+	}
+	return desc, manifest, nil
+}
+
+func manifestTotalSize(desc ocispec.Descriptor, manifest ocispec.Manifest) int64 {
+	size := desc.Size + manifest.Config.Size
+	for _, layer := range manifest.Layers {
+		size += layer.Size
+	}
+	return size
+}
+
+// volumeIndexFromManifest rebuilds the VolumeIndex of a packaged volume from
+// its manifest annotations without fetching any layer.
+func volumeIndexFromManifest(desc ocispec.Descriptor, manifest ocispec.Manifest) *VolumeIndex {
+	vi := &VolumeIndex{
+		VolumeRef:   desc.Digest.String(),
+		DisplayName: manifest.Annotations[ocispec.AnnotationTitle],
+		CreatedAt:   manifest.Annotations[ocispec.AnnotationCreated],
+		Layout:      manifest.Annotations[annotationPartitionLayout],
+		Partitions:  make([]Partition, 0, len(manifest.Layers)),
+	}
+	for _, layer := range manifest.Layers {
+		path := layer.Annotations[annotationPartitionPath]
+		if path == "" {
+			continue
+		}
+		compression, _ := layerCompression(layer.MediaType)
+		vi.Partitions = append(vi.Partitions, Partition{
+			Name:        path,
+			Path:        path,
+			ManifestRef: layer.Digest.String(),
+			CreatedAt:   vi.CreatedAt,
+			Compression: compression,
+		})
+	}
+	return vi
+}
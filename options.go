@@ -1,11 +1,28 @@
 package sori
 
+import "github.com/seoyhaein/sori/archiveutil"
+
 // PackageOptions controls the preferred core packaging path.
 //
 // This option surface is part of the stable core candidate contract.
 type PackageOptions struct {
 	ConfigBlob        []byte
 	RequireConfigBlob bool
+	// PartitionLayout selects PartitionLayoutNested (the default) or
+	// PartitionLayoutExclusive.
+	PartitionLayout string
+	// Compression selects the layer compression for every partition:
+	// CompressionGzip (the default), CompressionZstd, or CompressionNone,
+	// optionally with a level such as "gzip:6" or "zstd:19".
+	Compression string
+	// PartitionCompression overrides Compression for individual partitions,
+	// keyed by partition path such as "vol/a/b".
+	PartitionCompression map[string]string
+	// FullVerification archives and hashes every partition even when the
+	// packaging cache in the local store says its directory is unchanged.
+	// Without it, a partition whose paths, sizes, modification times, and
+	// inodes match the previous package reuses that layer unread.
+	FullVerification bool
 }
 
 // PushOptions controls the preferred core push path.
@@ -21,8 +38,43 @@ type PushOptions struct {
 type FetchOptions struct {
 	Concurrency             int
 	RequireEmptyDestination bool
+	// Remote, when set, fetches directly from the remote registry instead of
+	// the local OCI layout named by the repo argument.
+	Remote *RemoteTarget
+	// PullThroughCache, together with Remote, caches fetched blobs in the
+	// client's local OCI store and serves repeat fetches from it.
+	PullThroughCache bool
+	// Limits bounds what extraction may write across all layers. Exceeding a
+	// limit fails the fetch with ErrIntegrity. The zero value means no limits.
+	Limits ExtractLimits
+	// Partitions, when set, fetches only the partitions these entries select
+	// and lists only those in the returned VolumeIndex. An entry is a
+	// partition path such as "vol/annotation" or a path.Match glob such as
+	// "vol/chr*"; the leading volume directory may be left out. A path inside
+	// a partition selects the partition holding it, and under
+	// PartitionLayoutExclusive a selected partition brings its child
+	// partitions because their files are not in its layer. An entry that
+	// selects nothing fails the fetch with ErrNotFound.
+	Partitions []string
+	// Atomic extracts into a staging directory next to destRoot, checks
+	// that every partition and volume-index.json are present, and renames it
+	// to destRoot, so a failed or canceled fetch leaves nothing behind.
+	// destRoot must be missing or empty; otherwise the fetch fails with
+	// ErrConflict.
+	Atomic bool
+	// Incremental reads the volume-index.json of a previous fetch in destRoot
+	// and keeps the partitions whose layer digest is unchanged, reporting them
+	// with CacheStatus CacheReused. Changed partitions are cleared and
+	// extracted again, and partitions the fetch no longer covers are removed.
+	// Without a previous volume-index.json every partition is fetched. It
+	// cannot be combined with Atomic or RequireEmptyDestination.
+	Incremental bool
 }
 
+// ExtractLimits bounds total bytes, single file size, entry count, and path
+// depth during layer extraction. A zero field disables that limit.
+type ExtractLimits = archiveutil.ExtractLimits
+
 // ReferrerOptions controls the experimental referrer helpers.
 //
 // Experimental: this option surface belongs to the referrer API and is not yet
@@ -30,3 +82,10 @@ type FetchOptions struct {
 type ReferrerOptions struct {
 	Target RemoteTarget
 }
+
+// GCOptions controls Client.GarbageCollect.
+type GCOptions struct {
+	// DryRun reports unreachable blobs and reclaimable bytes without
+	// deleting anything.
+	DryRun bool
+}
@@ -0,0 +1,131 @@
+package sori
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/seoyhaein/sori/archiveutil"
+)
+
+// packageCacheFile is the packaging cache kept next to index.json in the
+// local OCI store.
+const packageCacheFile = "sori-package-cache.json"
+
+// packageCache remembers, per source directory and partition, the fingerprint
+// of the directory when its layer was last archived together with that layer
+// and the files it holds. A partition whose fingerprint has not changed reuses
+// the layer without reading its files.
+type packageCache struct {
+	path    string
+	Entries map[string]packageCacheEntry `json:"entries"`
+}
+
+type packageCacheEntry struct {
+	Fingerprint string             `json:"fingerprint"`
+	Layer       ocispec.Descriptor `json:"layer"`
+	Files       []VolumeFile       `json:"files"`
+}
+
+// loadPackageCache reads the cache in storePath. A missing or unreadable
+// cache yields an empty one, since every entry can be rebuilt by archiving.
+func loadPackageCache(storePath string) *packageCache {
+	c := &packageCache{
+		path:    filepath.Join(storePath, packageCacheFile),
+		Entries: make(map[string]packageCacheEntry),
+	}
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			Log.Warnf("ignoring packaging cache %s: %v", c.path, err)
+		}
+		return c
+	}
+	if err := json.Unmarshal(data, c); err != nil || c.Entries == nil {
+		Log.Warnf("ignoring corrupt packaging cache %s: %v", c.path, err)
+		c.Entries = make(map[string]packageCacheEntry)
+	}
+	return c
+}
+
+// lookup returns the cached layer of partPath archived from fsPath when its
+// fingerprint still matches.
+func (c *packageCache) lookup(fsPath, partPath, fingerprint string) (packageCacheEntry, bool) {
+	entry, ok := c.Entries[packageCacheKey(fsPath, partPath)]
+	if !ok || entry.Fingerprint != fingerprint {
+		return packageCacheEntry{}, false
+	}
+	return entry, true
+}
+
+func (c *packageCache) put(fsPath, partPath, fingerprint string, layer ocispec.Descriptor, files []VolumeFile) {
+	c.Entries[packageCacheKey(fsPath, partPath)] = packageCacheEntry{Fingerprint: fingerprint, Layer: layer, Files: files}
+}
+
+// prune drops entries whose layer blob is no longer in the store, for
+// example after GarbageCollect removed it.
+func (c *packageCache) prune() {
+	storePath := filepath.Dir(c.path)
+	for key, entry := range c.Entries {
+		dgst := entry.Layer.Digest
+		if dgst.Validate() == nil {
+			blob := filepath.Join(storePath, ocispec.ImageBlobsDir, dgst.Algorithm().String(), dgst.Encoded())
+			if _, err := os.Stat(blob); err == nil {
+				continue
+			}
+		}
+		delete(c.Entries, key)
+	}
+}
+
+// save prunes the cache and writes it through a temporary file so a
+// concurrent reader never sees a partial file.
+func (c *packageCache) save() error {
+	c.prune()
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), packageCacheFile+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+func packageCacheKey(fsPath, partPath string) string {
+	if abs, err := filepath.Abs(fsPath); err == nil {
+		fsPath = abs
+	}
+	return fsPath + "\n" + partPath
+}
+
+// layerFingerprint combines the directory fingerprint of fsPath with every
+// input that shapes the layer bytes besides file contents.
+func layerFingerprint(fsPath, partPath string, tarOpts archiveutil.TarOptions) (string, error) {
+	dir, err := archiveutil.DirFingerprint(fsPath, tarOpts.ExcludeDirs)
+	if err != nil {
+		return "", err
+	}
+	excluded := slices.Clone(tarOpts.ExcludeDirs)
+	slices.Sort(excluded)
+	return digest.FromString(fmt.Sprintf("%s\n%s\n%s\n%s", partPath, tarOpts.Compression, strings.Join(excluded, "\n"), dir)).String(), nil
+}
@@ -0,0 +1,149 @@
+package sori
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPackageVolume_CacheSkipsUnchangedPartitions(t *testing.T) {
+	ctx := context.Background()
+	storePath := filepath.Join(t.TempDir(), "oci")
+	var archived []string
+	client := NewClient(
+		WithLocalStorePath(storePath),
+		WithProgressReporter(ProgressFunc(func(e ProgressEvent) {
+			if e.Type == ProgressPartitionStarted {
+				archived = append(archived, e.Partition)
+			}
+		})),
+	)
+	src := filepath.Join(t.TempDir(), "vol")
+	writeTestVolume(t, src, "payload")
+	pkg := func(tag string, opts PackageOptions) *PackageResult {
+		t.Helper()
+		archived = nil
+		res, err := client.PackageVolumeWithOptions(ctx, PackageRequest{SourceDir: src, DisplayName: "Cache", Tag: tag}, opts)
+		if err != nil {
+			t.Fatalf("PackageVolume %s: %v", tag, err)
+		}
+		return res
+	}
+
+	first := pkg("cache.v1", PackageOptions{})
+	if !slices.Contains(archived, "vol/a") || !slices.Contains(archived, "vol/b") {
+		t.Fatalf("first package must archive every partition, archived %v", archived)
+	}
+	if _, err := os.Stat(filepath.Join(storePath, packageCacheFile)); err != nil {
+		t.Fatalf("expected packaging cache: %v", err)
+	}
+
+	again := pkg("cache.v1", PackageOptions{})
+	if len(archived) != 0 {
+		t.Fatalf("unchanged volume must not be archived, archived %v", archived)
+	}
+	if again.ManifestDigest != first.ManifestDigest {
+		t.Fatalf("manifest changed on cached repackage: %s != %s", again.ManifestDigest, first.ManifestDigest)
+	}
+
+	pkg("cache.v1", PackageOptions{FullVerification: true})
+	if !slices.Contains(archived, "vol/b") {
+		t.Fatalf("full verification must archive every partition, archived %v", archived)
+	}
+
+	writeTestFile(t, filepath.Join(src, "a", "a.txt"), "changed payload")
+	pkg("cache.v2", PackageOptions{})
+	if !slices.Contains(archived, "vol/a") || slices.Contains(archived, "vol/b") {
+		t.Fatalf("only the changed partition should be archived, archived %v", archived)
+	}
+
+	dest := filepath.Join(t.TempDir(), "restored")
+	if _, err := client.FetchVolume(ctx, dest, storePath, "cache.v2", FetchOptions{}); err != nil {
+		t.Fatalf("FetchVolume: %v", err)
+	}
+	report, err := client.VerifyVolume(ctx, dest)
+	if err != nil {
+		t.Fatalf("VerifyVolume: %v", err)
+	}
+	if !report.OK() || report.Verified == 0 {
+		t.Fatalf("file manifest of cached layers does not match: %+v", report)
+	}
+}
+
+func TestPackageVolume_CacheIgnoresMissingBlob(t *testing.T) {
+	ctx := context.Background()
+	storePath := filepath.Join(t.TempDir(), "oci")
+	client := NewClient(WithLocalStorePath(storePath))
+	src := filepath.Join(t.TempDir(), "vol")
+	writeTestVolume(t, src, "payload")
+	res, err := client.PackageVolume(ctx, PackageRequest{SourceDir: src, DisplayName: "Cache", Tag: "cache.v1"})
+	if err != nil {
+		t.Fatalf("PackageVolume: %v", err)
+	}
+	for _, p := range res.Partitions {
+		blob := filepath.Join(storePath, "blobs", "sha256", strings.TrimPrefix(p.ManifestRef, "sha256:"))
+		if err := os.Remove(blob); err != nil {
+			t.Fatalf("remove layer blob: %v", err)
+		}
+	}
+
+	if _, err := client.PackageVolume(ctx, PackageRequest{SourceDir: src, DisplayName: "Cache", Tag: "cache.v2"}); err != nil {
+		t.Fatalf("PackageVolume after blob removal: %v", err)
+	}
+	if _, err := client.FetchVolume(ctx, filepath.Join(t.TempDir(), "restored"), storePath, "cache.v2", FetchOptions{}); err != nil {
+		t.Fatalf("FetchVolume: %v", err)
+	}
+}
+
+func TestGarbageCollect_PrunesPackageCache(t *testing.T) {
+	ctx := context.Background()
+	storePath := filepath.Join(t.TempDir(), "oci")
+	client := NewClient(WithLocalStorePath(storePath))
+	kept := filepath.Join(t.TempDir(), "kept")
+	dropped := filepath.Join(t.TempDir(), "dropped")
+	writeTestVolume(t, kept, "kept")
+	writeTestVolume(t, dropped, "dropped")
+	writeTestFile(t, filepath.Join(dropped, "c", "c.txt"), "only here")
+	for tag, src := range map[string]string{"kept.v1": kept, "dropped.v1": dropped} {
+		if _, err := client.PackageVolume(ctx, PackageRequest{SourceDir: src, DisplayName: "Prune", Tag: tag}); err != nil {
+			t.Fatalf("PackageVolume %s: %v", tag, err)
+		}
+	}
+	entriesFor := func(src string) int {
+		t.Helper()
+		n := 0
+		for key := range loadPackageCache(storePath).Entries {
+			if strings.HasPrefix(key, src) {
+				n++
+			}
+		}
+		return n
+	}
+	before, keptBefore := entriesFor(dropped), entriesFor(kept)
+	if before == 0 || keptBefore == 0 {
+		t.Fatal("expected cache entries for both sources")
+	}
+
+	if err := client.Untag(ctx, "dropped.v1"); err != nil {
+		t.Fatalf("Untag: %v", err)
+	}
+	if _, err := client.GarbageCollect(ctx, GCOptions{}); err != nil {
+		t.Fatalf("GarbageCollect: %v", err)
+	}
+	// Layers the two volumes share survive, so only some entries go.
+	if n := entriesFor(dropped); n >= before {
+		t.Fatalf("expected entries of collected layers to be pruned, %d before and %d after", before, n)
+	}
+	if n := entriesFor(kept); n != keptBefore {
+		t.Fatalf("entries of the tagged volume must be kept, %d before and %d after", keptBefore, n)
+	}
+	for key, entry := range loadPackageCache(storePath).Entries {
+		blob := filepath.Join(storePath, "blobs", "sha256", entry.Layer.Digest.Encoded())
+		if _, err := os.Stat(blob); err != nil {
+			t.Fatalf("entry %q points at a missing layer: %v", key, err)
+		}
+	}
+}
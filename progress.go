@@ -0,0 +1,186 @@
+package sori
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+)
+
+// ProgressOperation names the client operation a ProgressEvent belongs to.
+type ProgressOperation string
+
+const (
+	ProgressPackage ProgressOperation = "package"
+	ProgressPush    ProgressOperation = "push"
+	ProgressFetch   ProgressOperation = "fetch"
+)
+
+// ProgressEventType describes what a ProgressEvent reports.
+type ProgressEventType string
+
+const (
+	// ProgressPartitionStarted is sent before a partition's layer is archived,
+	// uploaded, or extracted.
+	ProgressPartitionStarted ProgressEventType = "partition_started"
+	// ProgressBytes reports the bytes processed so far for one layer. It is
+	// sent every few MiB rather than on every read.
+	ProgressBytes ProgressEventType = "bytes"
+	// ProgressLayerSkipped is sent when a layer already exists in the
+	// destination and is not written again.
+	ProgressLayerSkipped ProgressEventType = "layer_skipped"
+	// ProgressLayerDone is sent when a layer has been fully processed.
+	ProgressLayerDone ProgressEventType = "layer_done"
+	// ProgressTotal is sent once when the operation succeeds and carries the
+	// total bytes processed and the number of layers.
+	ProgressTotal ProgressEventType = "total"
+)
+
+// ProgressEvent is one structured progress update.
+//
+// For layer events Partition is the partition path and Digest the layer
+// digest. Bytes is the running byte count of that layer, or the operation
+// total for ProgressTotal. Size is the expected layer size when known.
+type ProgressEvent struct {
+	Operation ProgressOperation `json:"operation"`
+	Type      ProgressEventType `json:"type"`
+	Partition string            `json:"partition,omitempty"`
+	Digest    string            `json:"digest,omitempty"`
+	Bytes     int64             `json:"bytes"`
+	Size      int64             `json:"size,omitempty"`
+	Layers    int               `json:"layers,omitempty"`
+}
+
+// ProgressReporter receives progress events from package, push, and fetch.
+// Calls are serialized by the client, so implementations need no locking,
+// but they should return quickly because they run on the transfer path.
+type ProgressReporter interface {
+	Report(ProgressEvent)
+}
+
+// ProgressFunc adapts a function to ProgressReporter.
+type ProgressFunc func(ProgressEvent)
+
+// Report calls f(event).
+func (f ProgressFunc) Report(event ProgressEvent) {
+	f(event)
+}
+
+// WithProgressReporter sets the reporter that receives progress events for
+// PackageVolume, PushPackagedVolume, and FetchVolume.
+func WithProgressReporter(reporter ProgressReporter) ClientOption {
+	return func(c *Client) {
+		c.progress = reporter
+	}
+}
+
+// progressInterval is the minimum number of bytes between ProgressBytes
+// events for a single layer.
+const progressInterval = 4 << 20
+
+// progressSink serializes events for one operation and keeps the totals. A
+// nil sink discards everything, so call sites need no nil checks.
+type progressSink struct {
+	mu         sync.Mutex
+	reporter   ProgressReporter
+	op         ProgressOperation
+	totalBytes int64
+	layers     int
+}
+
+func newProgressSink(reporter ProgressReporter, op ProgressOperation) *progressSink {
+	if reporter == nil {
+		return nil
+	}
+	return &progressSink{reporter: reporter, op: op}
+}
+
+func (s *progressSink) emit(event ProgressEvent) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	event.Operation = s.op
+	switch event.Type {
+	case ProgressLayerDone:
+		s.totalBytes += event.Bytes
+		s.layers++
+	case ProgressLayerSkipped:
+		s.layers++
+	case ProgressTotal:
+		event.Bytes = s.totalBytes
+		event.Layers = s.layers
+	}
+	s.reporter.Report(event)
+}
+
+func (s *progressSink) partitionStarted(partition string, desc ocispec.Descriptor) {
+	s.emit(ProgressEvent{Type: ProgressPartitionStarted, Partition: partition, Digest: descDigest(desc), Size: desc.Size})
+}
+
+func (s *progressSink) layerSkipped(partition string, desc ocispec.Descriptor) {
+	s.emit(ProgressEvent{Type: ProgressLayerSkipped, Partition: partition, Digest: descDigest(desc), Size: desc.Size})
+}
+
+func (s *progressSink) layerDone(partition string, desc ocispec.Descriptor, n int64) {
+	s.emit(ProgressEvent{Type: ProgressLayerDone, Partition: partition, Digest: descDigest(desc), Bytes: n, Size: desc.Size})
+}
+
+func (s *progressSink) total() {
+	s.emit(ProgressEvent{Type: ProgressTotal})
+}
+
+// counter returns a callback that takes the running byte count of a layer and
+// emits ProgressBytes every progressInterval bytes.
+func (s *progressSink) counter(partition string, desc ocispec.Descriptor) func(n int64) {
+	if s == nil {
+		return func(int64) {}
+	}
+	var last int64
+	return func(n int64) {
+		if n-last < progressInterval {
+			return
+		}
+		last = n
+		s.emit(ProgressEvent{Type: ProgressBytes, Partition: partition, Digest: descDigest(desc), Bytes: n, Size: desc.Size})
+	}
+}
+
+func descDigest(desc ocispec.Descriptor) string {
+	if desc.Digest == "" {
+		return ""
+	}
+	return desc.Digest.String()
+}
+
+// progressReader counts bytes read through it and forwards the running total
+// to onRead.
+type progressReader struct {
+	io.ReadCloser
+	n      int64
+	onRead func(int64)
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	r.onRead(r.n)
+	return n, err
+}
+
+// progressSource wraps a copy source so blob reads report ProgressBytes.
+type progressSource struct {
+	oras.ReadOnlyTarget
+	sink *progressSink
+}
+
+func (s progressSource) Fetch(ctx context.Context, desc ocispec.Descriptor) (io.ReadCloser, error) {
+	rc, err := s.ReadOnlyTarget.Fetch(ctx, desc)
+	if err != nil {
+		return nil, err
+	}
+	return &progressReader{ReadCloser: rc, onRead: s.sink.counter(desc.Annotations[annotationPartitionPath], desc)}, nil
+}
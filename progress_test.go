@@ -0,0 +1,72 @@
+package sori
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestClientProgressReporter_PackageAndFetch(t *testing.T) {
+	ctx := context.Background()
+	storePath := filepath.Join(t.TempDir(), "oci")
+	var events []ProgressEvent
+	client := NewClient(
+		WithLocalStorePath(storePath),
+		WithProgressReporter(ProgressFunc(func(e ProgressEvent) { events = append(events, e) })),
+	)
+	req := PackageRequest{SourceDir: "./test-vol", DisplayName: "Progress", Tag: "progress.v1"}
+
+	count := func(op ProgressOperation, typ ProgressEventType) int {
+		n := 0
+		for _, e := range events {
+			if e.Operation == op && e.Type == typ {
+				n++
+			}
+		}
+		return n
+	}
+	last := func() ProgressEvent { return events[len(events)-1] }
+
+	pkg, err := client.PackageVolume(ctx, req)
+	if err != nil {
+		t.Fatalf("PackageVolume: %v", err)
+	}
+	layers := len(pkg.Partitions)
+	if got := count(ProgressPackage, ProgressPartitionStarted); got != layers {
+		t.Fatalf("package partition_started: got %d want %d", got, layers)
+	}
+	if got := count(ProgressPackage, ProgressLayerDone); got != layers {
+		t.Fatalf("package layer_done: got %d want %d", got, layers)
+	}
+	if e := last(); e.Type != ProgressTotal || e.Layers != layers || e.Bytes <= 0 {
+		t.Fatalf("unexpected final package event: %+v", e)
+	}
+
+	events = nil
+	if _, err := client.PackageVolume(ctx, req); err != nil {
+		t.Fatalf("PackageVolume again: %v", err)
+	}
+	if got := count(ProgressPackage, ProgressLayerSkipped); got != layers {
+		t.Fatalf("repackage layer_skipped: got %d want %d", got, layers)
+	}
+
+	events = nil
+	if _, err := client.FetchVolume(ctx, filepath.Join(t.TempDir(), "restored"), storePath, req.Tag, FetchOptions{Concurrency: 2}); err != nil {
+		t.Fatalf("FetchVolume: %v", err)
+	}
+	if got := count(ProgressFetch, ProgressLayerDone); got != layers {
+		t.Fatalf("fetch layer_done: got %d want %d", got, layers)
+	}
+	var want int64
+	for _, e := range events {
+		if e.Type == ProgressLayerDone {
+			if e.Bytes != e.Size {
+				t.Fatalf("fetch layer %s: read %d bytes, size %d", e.Digest, e.Bytes, e.Size)
+			}
+			want += e.Bytes
+		}
+	}
+	if e := last(); e.Type != ProgressTotal || e.Bytes != want {
+		t.Fatalf("unexpected final fetch event: %+v (want bytes %d)", e, want)
+	}
+}
@@ -103,6 +103,59 @@ func TestPushDataSpecReferrer_Success(t *testing.T) {
 	}
 }
 
+func TestPushToolSpecReferrer_ReopensLocalStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := oci.New(dir)
+	if err != nil {
+		t.Fatalf("oci.New: %v", err)
+	}
+	subjectDigest := pushFakeSubject(t, ctx, store)
+	specJSON, _ := sori.MarshalSpec(map[string]string{"tool": "bwa"})
+	if _, err := sori.PushToolSpecReferrer(ctx, store, subjectDigest, specJSON); err != nil {
+		t.Fatalf("PushToolSpecReferrer: %v", err)
+	}
+	if _, err := oci.New(dir); err != nil {
+		t.Fatalf("reopen store with referrer: %v", err)
+	}
+}
+
+func TestPushToolSpecReferrer_MissingSubject(t *testing.T) {
+	ctx := context.Background()
+	store := newTestOCIStore(t)
+	specJSON, _ := sori.MarshalSpec(map[string]string{"tool": "bwa"})
+	missing := godigest.FromString("missing").String()
+	result, err := sori.PushToolSpecReferrer(ctx, store, missing, specJSON)
+	if err != nil {
+		t.Fatalf("PushToolSpecReferrer: %v", err)
+	}
+	if result.SubjectDigest != missing {
+		t.Errorf("SubjectDigest: got %q want %q", result.SubjectDigest, missing)
+	}
+}
+
+// resolveErrorTarget fails every Resolve with err.
+type resolveErrorTarget struct {
+	orasoras.Target
+	err error
+}
+
+func (t resolveErrorTarget) Resolve(context.Context, string) (ocispec.Descriptor, error) {
+	return ocispec.Descriptor{}, t.err
+}
+
+func TestPushToolSpecReferrer_ResolveErrorPropagates(t *testing.T) {
+	ctx := context.Background()
+	store := newTestOCIStore(t)
+	subjectDigest := pushFakeSubject(t, ctx, store)
+	specJSON, _ := sori.MarshalSpec(map[string]string{"tool": "bwa"})
+	resolveErr := errors.New("connection reset")
+	_, err := sori.PushToolSpecReferrer(ctx, resolveErrorTarget{Target: store, err: resolveErr}, subjectDigest, specJSON)
+	if !errors.Is(err, resolveErr) {
+		t.Fatalf("expected the resolve error, got %v", err)
+	}
+}
+
 func TestPushToolSpecReferrer_EmptySubjectDigest(t *testing.T) {
 	ctx := context.Background()
 	store := newTestOCIStore(t)
@@ -0,0 +1,98 @@
+package registryutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/credentials"
+)
+
+// Credential stores accepted by CredentialSource.Store.
+const (
+	// CredentialStoreDocker follows Podman's lookup order: $REGISTRY_AUTH_FILE
+	// when set, then $XDG_RUNTIME_DIR/containers/auth.json, then the Docker
+	// config ($DOCKER_CONFIG/config.json or ~/.docker/config.json). The first
+	// file holding a credential for the registry wins. credsStore and
+	// credHelpers entries in those files run the matching helpers.
+	CredentialStoreDocker = "docker"
+	// CredentialStoreFile reads a single Docker-format auth file.
+	CredentialStoreFile = "file"
+	// CredentialStoreHelper runs docker-credential-<Helper> directly.
+	CredentialStoreHelper = "helper"
+)
+
+// CredentialSource selects where registry credentials are looked up instead
+// of static username, password, and token values.
+type CredentialSource struct {
+	Store string
+	// ConfigFile is the auth file read by CredentialStoreFile.
+	ConfigFile string
+	// Helper is the helper suffix used by CredentialStoreHelper, for example
+	// "pass" for docker-credential-pass.
+	Helper string
+}
+
+// NewCredentialFunc builds an auth.CredentialFunc for RemoteConfig.AuthProvider
+// from a credential source.
+func NewCredentialFunc(src CredentialSource) (auth.CredentialFunc, error) {
+	switch src.Store {
+	case CredentialStoreDocker:
+		store, err := dockerCredentialStore()
+		if err != nil {
+			return nil, err
+		}
+		return credentials.Credential(store), nil
+	case CredentialStoreFile:
+		if src.ConfigFile == "" {
+			return nil, validationError("NewCredentialFunc", "config file is required for the file credential store", nil)
+		}
+		if _, err := os.Stat(src.ConfigFile); err != nil {
+			return nil, validationError("NewCredentialFunc", "stat credential file "+src.ConfigFile, err)
+		}
+		store, err := credentials.NewStore(src.ConfigFile, credentials.StoreOptions{})
+		if err != nil {
+			return nil, validationError("NewCredentialFunc", "load credential file "+src.ConfigFile, err)
+		}
+		return credentials.Credential(store), nil
+	case CredentialStoreHelper:
+		if src.Helper == "" {
+			return nil, validationError("NewCredentialFunc", "helper is required for the helper credential store", nil)
+		}
+		return credentials.Credential(credentials.NewNativeStore(src.Helper)), nil
+	default:
+		return nil, validationError("NewCredentialFunc", fmt.Sprintf("unknown credential store %q", src.Store), nil)
+	}
+}
+
+// dockerCredentialStore chains the auth files Podman consults, in the order
+// described on CredentialStoreDocker.
+func dockerCredentialStore() (credentials.Store, error) {
+	var stores []credentials.Store
+	if path := os.Getenv("REGISTRY_AUTH_FILE"); path != "" {
+		store, err := credentials.NewStore(path, credentials.StoreOptions{})
+		if err != nil {
+			return nil, validationError("NewCredentialFunc", "load $REGISTRY_AUTH_FILE "+path, err)
+		}
+		stores = append(stores, store)
+	}
+
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		path := filepath.Join(dir, "containers", "auth.json")
+		if _, err := os.Stat(path); err == nil {
+			store, err := credentials.NewStore(path, credentials.StoreOptions{})
+			if err != nil {
+				return nil, validationError("NewCredentialFunc", "load podman auth file "+path, err)
+			}
+			stores = append(stores, store)
+		}
+	}
+
+	dockerStore, err := credentials.NewStoreFromDocker(credentials.StoreOptions{DetectDefaultNativeStore: true})
+	if err != nil {
+		return nil, validationError("NewCredentialFunc", "load docker config", err)
+	}
+	stores = append(stores, dockerStore)
+	return credentials.NewStoreWithFallbacks(stores[0], stores[1:]...), nil
+}
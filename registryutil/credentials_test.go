@@ -0,0 +1,135 @@
+package registryutil
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func writeAuthFile(t *testing.T, dir, registry, username, password string) string {
+	t.Helper()
+	auth := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	path := filepath.Join(dir, "config.json")
+	data := fmt.Sprintf(`{"auths":{%q:{"auth":%q}}}`, registry, auth)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestNewCredentialFunc_File(t *testing.T) {
+	path := writeAuthFile(t, t.TempDir(), "harbor.local", "robot", "s3cret")
+	cred, err := NewCredentialFunc(CredentialSource{Store: CredentialStoreFile, ConfigFile: path})
+	if err != nil {
+		t.Fatalf("NewCredentialFunc: %v", err)
+	}
+	got, err := cred(context.Background(), "harbor.local")
+	if err != nil {
+		t.Fatalf("credential lookup: %v", err)
+	}
+	if got.Username != "robot" || got.Password != "s3cret" {
+		t.Fatalf("unexpected credential: %+v", got)
+	}
+}
+
+func TestNewCredentialFunc_DockerPrefersRegistryAuthFile(t *testing.T) {
+	dockerDir := t.TempDir()
+	writeAuthFile(t, dockerDir, "harbor.local", "docker-user", "docker-pass")
+	t.Setenv("DOCKER_CONFIG", dockerDir)
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	cred, err := NewCredentialFunc(CredentialSource{Store: CredentialStoreDocker})
+	if err != nil {
+		t.Fatalf("NewCredentialFunc: %v", err)
+	}
+	got, err := cred(context.Background(), "harbor.local")
+	if err != nil {
+		t.Fatalf("credential lookup: %v", err)
+	}
+	if got.Username != "docker-user" {
+		t.Fatalf("expected docker config credential, got %+v", got)
+	}
+
+	t.Setenv("REGISTRY_AUTH_FILE", writeAuthFile(t, t.TempDir(), "harbor.local", "podman-user", "podman-pass"))
+	cred, err = NewCredentialFunc(CredentialSource{Store: CredentialStoreDocker})
+	if err != nil {
+		t.Fatalf("NewCredentialFunc: %v", err)
+	}
+	got, err = cred(context.Background(), "harbor.local")
+	if err != nil {
+		t.Fatalf("credential lookup: %v", err)
+	}
+	if got.Username != "podman-user" || got.Password != "podman-pass" {
+		t.Fatalf("expected $REGISTRY_AUTH_FILE credential, got %+v", got)
+	}
+}
+
+func TestNewCredentialFunc_DockerFollowsPodmanOrder(t *testing.T) {
+	dockerDir := t.TempDir()
+	writeAuthFile(t, dockerDir, "harbor.local", "docker-user", "docker-pass")
+	t.Setenv("DOCKER_CONFIG", dockerDir)
+	t.Setenv("REGISTRY_AUTH_FILE", "")
+	runtimeDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(runtimeDir, "containers"), 0o700); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	podmanAuth := writeAuthFile(t, filepath.Join(runtimeDir, "containers"), "harbor.local", "podman-user", "podman-pass")
+	if err := os.Rename(podmanAuth, filepath.Join(runtimeDir, "containers", "auth.json")); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+
+	cred, err := NewCredentialFunc(CredentialSource{Store: CredentialStoreDocker})
+	if err != nil {
+		t.Fatalf("NewCredentialFunc: %v", err)
+	}
+	got, err := cred(context.Background(), "harbor.local")
+	if err != nil {
+		t.Fatalf("credential lookup: %v", err)
+	}
+	if got.Username != "podman-user" || got.Password != "podman-pass" {
+		t.Fatalf("expected the Podman auth file to win over the docker config, got %+v", got)
+	}
+}
+
+func TestNewCredentialFunc_Helper(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell credential helper requires a POSIX shell")
+	}
+	binDir := t.TempDir()
+	script := "#!/bin/sh\nread server\necho '{\"ServerURL\":\"'$server'\",\"Username\":\"helper-user\",\"Secret\":\"helper-pass\"}'\n"
+	if err := os.WriteFile(filepath.Join(binDir, "docker-credential-sori-test"), []byte(script), 0o755); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	cred, err := NewCredentialFunc(CredentialSource{Store: CredentialStoreHelper, Helper: "sori-test"})
+	if err != nil {
+		t.Fatalf("NewCredentialFunc: %v", err)
+	}
+	got, err := cred(context.Background(), "harbor.local")
+	if err != nil {
+		t.Fatalf("credential lookup: %v", err)
+	}
+	if got.Username != "helper-user" || got.Password != "helper-pass" {
+		t.Fatalf("unexpected credential: %+v", got)
+	}
+}
+
+func TestNewCredentialFunc_InvalidSourceTypedError(t *testing.T) {
+	for _, src := range []CredentialSource{
+		{Store: "vault"},
+		{Store: CredentialStoreFile},
+		{Store: CredentialStoreFile, ConfigFile: filepath.Join(t.TempDir(), "missing.json")},
+		{Store: CredentialStoreHelper},
+	} {
+		if _, err := NewCredentialFunc(src); !errors.Is(err, ErrValidation) {
+			t.Fatalf("%+v: expected ErrValidation, got %v", src, err)
+		}
+	}
+}
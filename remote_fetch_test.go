@@ -0,0 +1,452 @@
+package sori
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content/oci"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry"
+	"oras.land/oras-go/v2/registry/remote/errcode"
+)
+
+// testRegistry is a minimal OCI distribution endpoint backed by a local OCI
+// layout, just enough for remote.Repository to resolve, fetch, and push.
+type testRegistry struct {
+	root     string
+	store    *oci.Store
+	server   *httptest.Server
+	requests atomic.Int64
+	// username and password, when set, are required as basic auth.
+	username string
+	password string
+}
+
+func newTestRegistry(t *testing.T, root string) *testRegistry {
+	t.Helper()
+	store, err := oci.New(root)
+	if err != nil {
+		t.Fatalf("oci.New: %v", err)
+	}
+	r := &testRegistry{root: root, store: store}
+	r.server = httptest.NewServer(http.HandlerFunc(r.serveHTTP))
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+func (r *testRegistry) target(repository string) RemoteTarget {
+	return RemoteTarget{
+		Registry:   strings.TrimPrefix(r.server.URL, "http://"),
+		Repository: repository,
+		PlainHTTP:  true,
+	}
+}
+
+func (r *testRegistry) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	r.requests.Add(1)
+	if r.username != "" {
+		user, pass, ok := req.BasicAuth()
+		if !ok || user != r.username || pass != r.password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+	if req.URL.Path == "/v2/" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	path := req.URL.Path
+	switch {
+	case req.Method == http.MethodPost && strings.HasSuffix(path, "/blobs/uploads/"):
+		w.Header().Set("Location", path+"upload")
+		w.WriteHeader(http.StatusAccepted)
+		return
+	case req.Method == http.MethodPut && strings.Contains(path, "/blobs/uploads/"):
+		r.putBlob(w, req, digest.Digest(req.URL.Query().Get("digest")), "application/octet-stream")
+		return
+	case req.Method == http.MethodPut && strings.Contains(path, "/manifests/"):
+		r.putManifest(w, req, path[strings.LastIndex(path, "/manifests/")+len("/manifests/"):])
+		return
+	}
+
+	if req.Method == http.MethodGet && strings.Contains(path, "/referrers/") {
+		r.serveReferrers(w, req, path[strings.LastIndex(path, "/referrers/")+len("/referrers/"):])
+		return
+	}
+
+	mediaType := "application/octet-stream"
+	var ref string
+	switch {
+	case strings.Contains(path, "/manifests/"):
+		ref = path[strings.LastIndex(path, "/manifests/")+len("/manifests/"):]
+		desc, err := r.store.Resolve(req.Context(), ref)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		ref = desc.Digest.String()
+		mediaType = desc.MediaType
+	case strings.Contains(path, "/blobs/"):
+		ref = path[strings.LastIndex(path, "/blobs/")+len("/blobs/"):]
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	dgst, err := digest.Parse(ref)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	data, err := os.ReadFile(filepath.Join(r.root, "blobs", dgst.Algorithm().String(), dgst.Encoded()))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Docker-Content-Digest", dgst.String())
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+	if req.Method != http.MethodHead {
+		_, _ = w.Write(data)
+	}
+}
+
+// serveReferrers answers the referrers API from the layout's predecessor
+// graph, filtered by the artifactType query parameter.
+func (r *testRegistry) serveReferrers(w http.ResponseWriter, req *http.Request, ref string) {
+	subject, err := r.store.Resolve(req.Context(), ref)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	referrers, err := registry.Referrers(req.Context(), r.store, subject, req.URL.Query().Get("artifactType"))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if referrers == nil {
+		referrers = []ocispec.Descriptor{}
+	}
+	index := ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: referrers,
+	}
+	w.Header().Set("Content-Type", ocispec.MediaTypeImageIndex)
+	_ = json.NewEncoder(w).Encode(index)
+}
+
+func (r *testRegistry) putBlob(w http.ResponseWriter, req *http.Request, dgst digest.Digest, mediaType string) (ocispec.Descriptor, bool) {
+	data, err := io.ReadAll(req.Body)
+	if err != nil || dgst.Validate() != nil || digest.FromBytes(data) != dgst {
+		w.WriteHeader(http.StatusBadRequest)
+		return ocispec.Descriptor{}, false
+	}
+	desc := ocispec.Descriptor{MediaType: mediaType, Digest: dgst, Size: int64(len(data))}
+	if err := r.store.Push(req.Context(), desc, bytes.NewReader(data)); err != nil && !errors.Is(err, errdef.ErrAlreadyExists) {
+		w.WriteHeader(http.StatusInternalServerError)
+		return ocispec.Descriptor{}, false
+	}
+	w.Header().Set("Docker-Content-Digest", dgst.String())
+	w.WriteHeader(http.StatusCreated)
+	return desc, true
+}
+
+func (r *testRegistry) putManifest(w http.ResponseWriter, req *http.Request, ref string) {
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	desc, ok := r.putBlob(w, req, digest.FromBytes(data), req.Header.Get("Content-Type"))
+	if !ok {
+		return
+	}
+	if _, err := digest.Parse(ref); err != nil {
+		_ = r.store.Tag(req.Context(), desc, ref)
+	}
+}
+
+// newTestRemoteVolume packages ./test-vol into an OCI layout and serves it
+// from a test registry under tag.
+func newTestRemoteVolume(t *testing.T, tag string) (*testRegistry, *PackageResult) {
+	t.Helper()
+	root := filepath.Join(t.TempDir(), "remote-oci")
+	pkg, err := PackageVolumeToStore(context.Background(), root, PackageRequest{
+		SourceDir:   "./test-vol",
+		DisplayName: "Remote Volume",
+		Tag:         tag,
+	})
+	if err != nil {
+		t.Fatalf("PackageVolumeToStore: %v", err)
+	}
+	return newTestRegistry(t, root), pkg
+}
+
+func TestFetchRemoteVolume(t *testing.T) {
+	reg, pkg := newTestRemoteVolume(t, "remote.v1")
+	dest := filepath.Join(t.TempDir(), "restored")
+
+	vi, err := FetchRemoteVolume(context.Background(), dest, reg.target("data/ref"), "remote.v1", 2)
+	if err != nil {
+		t.Fatalf("FetchRemoteVolume: %v", err)
+	}
+	if vi.VolumeRef != pkg.ManifestDigest {
+		t.Fatalf("VolumeRef mismatch: got %q want %q", vi.VolumeRef, pkg.ManifestDigest)
+	}
+	if len(vi.Partitions) != len(pkg.Partitions) {
+		t.Fatalf("partition count: got %d want %d", len(vi.Partitions), len(pkg.Partitions))
+	}
+	if _, err := os.Stat(filepath.Join(dest, "test-vol", "docs", "test111", "test.txt")); err != nil {
+		t.Fatalf("expected restored file: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, VolumeIndexJson)); err != nil {
+		t.Fatalf("expected volume index: %v", err)
+	}
+}
+
+func TestClientFetchVolume_RemoteReference(t *testing.T) {
+	reg, pkg := newTestRemoteVolume(t, "remote.v1")
+	dest := filepath.Join(t.TempDir(), "restored")
+	target := reg.target("data/ref")
+
+	client := NewClient(WithLocalStorePath(filepath.Join(t.TempDir(), "oci")))
+	vi, err := client.FetchVolume(context.Background(), dest, target.Registry+"/"+target.Repository, pkg.ManifestDigest, FetchOptions{
+		Remote: &RemoteTarget{PlainHTTP: true},
+	})
+	if err != nil {
+		t.Fatalf("FetchVolume: %v", err)
+	}
+	if vi.VolumeRef != pkg.ManifestDigest {
+		t.Fatalf("VolumeRef mismatch: got %q want %q", vi.VolumeRef, pkg.ManifestDigest)
+	}
+}
+
+func TestFetchRemoteVolume_MissingTagTypedError(t *testing.T) {
+	reg, _ := newTestRemoteVolume(t, "remote.v1")
+	_, err := FetchRemoteVolume(context.Background(), t.TempDir(), reg.target("data/ref"), "missing", 1)
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestClientFetchVolume_PullThroughCache(t *testing.T) {
+	reg, pkg := newTestRemoteVolume(t, "remote.v1")
+	ctx := context.Background()
+	target := reg.target("data/ref")
+	client := NewClient(WithLocalStorePath(filepath.Join(t.TempDir(), "oci")))
+	opts := FetchOptions{Concurrency: 2, Remote: &target, PullThroughCache: true}
+
+	first, err := client.FetchVolume(ctx, filepath.Join(t.TempDir(), "first"), "", "remote.v1", opts)
+	if err != nil {
+		t.Fatalf("first FetchVolume: %v", err)
+	}
+	for _, p := range first.Partitions {
+		if p.CacheStatus != CacheMiss {
+			t.Fatalf("first fetch partition %q: got cache status %q want %q", p.Path, p.CacheStatus, CacheMiss)
+		}
+	}
+	cachedTag := remoteRepositoryRef(target) + ":remote.v1"
+	inspected, err := client.InspectLocal(ctx, cachedTag)
+	if err != nil {
+		t.Fatalf("InspectLocal %s: %v", cachedTag, err)
+	}
+	if inspected.Digest != pkg.ManifestDigest {
+		t.Fatalf("cached tag digest mismatch: got %q want %q", inspected.Digest, pkg.ManifestDigest)
+	}
+
+	requests := reg.requests.Load()
+	second, err := client.FetchVolume(ctx, filepath.Join(t.TempDir(), "second"), "", "remote.v1", opts)
+	if err != nil {
+		t.Fatalf("second FetchVolume: %v", err)
+	}
+	if got := reg.requests.Load(); got != requests {
+		t.Fatalf("expected cached fetch without registry traffic, got %d new requests", got-requests)
+	}
+	if second.VolumeRef != pkg.ManifestDigest {
+		t.Fatalf("VolumeRef mismatch: got %q want %q", second.VolumeRef, pkg.ManifestDigest)
+	}
+	for _, p := range second.Partitions {
+		if p.CacheStatus != CacheHit {
+			t.Fatalf("second fetch partition %q: got cache status %q want %q", p.Path, p.CacheStatus, CacheHit)
+		}
+	}
+
+	if _, err := client.FetchVolume(ctx, filepath.Join(t.TempDir(), "local"), client.LocalStorePath(), pkg.ManifestDigest, FetchOptions{}); err != nil {
+		t.Fatalf("local FetchVolume by digest from cache: %v", err)
+	}
+}
+
+func TestPullThroughTarget_TagsOnlyCompleteManifests(t *testing.T) {
+	reg, pkg := newTestRemoteVolume(t, "remote.v1")
+	ctx := context.Background()
+	target := reg.target("data/ref")
+	repo, remoteRepo, err := openRemoteFetchRepository("test", target, "remote.v1")
+	if err != nil {
+		t.Fatalf("openRemoteFetchRepository: %v", err)
+	}
+	pt, err := newPullThroughTarget(repo, filepath.Join(t.TempDir(), "oci"), remoteRepo)
+	if err != nil {
+		t.Fatalf("newPullThroughTarget: %v", err)
+	}
+
+	desc, err := pt.Resolve(ctx, "remote.v1")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if _, err := pt.cache.Resolve(ctx, pt.cacheRef("remote.v1")); !errors.Is(err, errdef.ErrNotFound) {
+		t.Fatalf("tag must wait for the layers, got %v", err)
+	}
+	_, manifest, err := fetchManifest(ctx, "test", pt.cache, desc.Digest.String())
+	if err != nil {
+		t.Fatalf("fetch cached manifest: %v", err)
+	}
+	if exists, err := pt.cache.Exists(ctx, manifest.Config); err != nil || !exists {
+		t.Fatalf("config must be cached with the manifest: exists %v, %v", exists, err)
+	}
+
+	for _, layer := range manifest.Layers {
+		rc, err := pt.Fetch(ctx, layer)
+		if err != nil {
+			t.Fatalf("Fetch layer: %v", err)
+		}
+		rc.Close()
+	}
+	got, err := pt.cache.Resolve(ctx, pt.cacheRef("remote.v1"))
+	if err != nil {
+		t.Fatalf("tag after caching every layer: %v", err)
+	}
+	if got.Digest.String() != pkg.ManifestDigest {
+		t.Fatalf("cached tag digest mismatch: got %s want %s", got.Digest, pkg.ManifestDigest)
+	}
+}
+
+func TestFetchRemoteVolume_UnauthorizedTypedError(t *testing.T) {
+	reg, _ := newTestRemoteVolume(t, "remote.v1")
+	reg.username, reg.password = "sori", "secret"
+	target := reg.target("data/ref")
+	target.Username, target.Password = "sori", "wrong"
+
+	_, err := FetchRemoteVolume(context.Background(), t.TempDir(), target, "remote.v1", 1)
+	if !errors.Is(err, ErrAuth) {
+		t.Fatalf("expected ErrAuth, got %v", err)
+	}
+	var serr *Error
+	if !errors.As(err, &serr) || serr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected status 401 on error, got %+v", serr)
+	}
+}
+
+func TestRegistryError_Classification(t *testing.T) {
+	resp := func(status int, code string) error {
+		r := &errcode.ErrorResponse{Method: http.MethodGet, StatusCode: status}
+		if code != "" {
+			r.Errors = errcode.Errors{{Code: code, Message: "test"}}
+		}
+		return r
+	}
+	cases := []struct {
+		name   string
+		err    error
+		target error
+		status int
+		code   string
+	}{
+		{"unauthorized", resp(http.StatusUnauthorized, errcode.ErrorCodeUnauthorized), ErrAuth, http.StatusUnauthorized, errcode.ErrorCodeUnauthorized},
+		{"forbidden", resp(http.StatusForbidden, ""), ErrAuth, http.StatusForbidden, ""},
+		{"denied code", resp(http.StatusBadRequest, errcode.ErrorCodeDenied), ErrAuth, http.StatusBadRequest, errcode.ErrorCodeDenied},
+		{"manifest unknown", resp(http.StatusNotFound, errcode.ErrorCodeManifestUnknown), ErrNotFound, http.StatusNotFound, errcode.ErrorCodeManifestUnknown},
+		{"conflict", resp(http.StatusConflict, ""), ErrConflict, http.StatusConflict, ""},
+		{"server error", resp(http.StatusInternalServerError, ""), ErrTransport, http.StatusInternalServerError, ""},
+		{"wrapped", fmt.Errorf("push: %w", resp(http.StatusUnauthorized, "")), ErrAuth, http.StatusUnauthorized, ""},
+		{"store not found", fmt.Errorf("tag: %w", errdef.ErrNotFound), ErrNotFound, 0, ""},
+		{"plain", errors.New("connection refused"), ErrTransport, 0, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := registryError("op", "call registry", tc.err)
+			if !errors.Is(err, tc.target) {
+				t.Fatalf("got %v, want kind of %v", err, tc.target)
+			}
+			var serr *Error
+			if !errors.As(err, &serr) || serr.StatusCode != tc.status || serr.Code != tc.code {
+				t.Fatalf("status/code: got %+v", serr)
+			}
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("cause not preserved: %v", err)
+			}
+		})
+	}
+}
+
+// recordingTransport records the request paths a remote.Repository sends.
+type recordingTransport struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+func (rt *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.mu.Lock()
+	rt.paths = append(rt.paths, req.Method+" "+req.URL.Path)
+	rt.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestClientInspectRemote(t *testing.T) {
+	reg, pkg := newTestRemoteVolume(t, "remote.v1")
+	rt := &recordingTransport{}
+	target := reg.target("data/ref")
+	target.Transport = rt
+
+	res, err := NewClient().InspectRemote(context.Background(), target, "remote.v1")
+	if err != nil {
+		t.Fatalf("InspectRemote: %v", err)
+	}
+	if res.Digest != pkg.ManifestDigest {
+		t.Fatalf("digest: got %s want %s", res.Digest, pkg.ManifestDigest)
+	}
+	var layerSize int64
+	for _, l := range res.Manifest.Layers {
+		layerSize += l.Size
+	}
+	if res.LayerSize == 0 || res.LayerSize != layerSize || res.Size <= res.LayerSize {
+		t.Fatalf("sizes: layer %d (manifest says %d), total %d", res.LayerSize, layerSize, res.Size)
+	}
+	if res.Volume == nil || len(res.Volume.Partitions) != len(pkg.Partitions) {
+		t.Fatalf("unexpected volume view: %+v", res.Volume)
+	}
+	if len(res.Config) == 0 {
+		t.Fatal("expected config blob")
+	}
+	for _, p := range pkg.Partitions {
+		for _, path := range rt.paths {
+			if strings.HasSuffix(path, p.ManifestRef) {
+				t.Fatalf("InspectRemote downloaded layer %s", path)
+			}
+		}
+	}
+
+	if _, err := NewClient().InspectRemote(context.Background(), target, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
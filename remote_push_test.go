@@ -0,0 +1,102 @@
+package sori
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClientPushToRemote(t *testing.T) {
+	ctx := context.Background()
+	reg := newTestRegistry(t, filepath.Join(t.TempDir(), "registry"))
+	reg.username, reg.password = "sori", "secret"
+	target := reg.target("data/ref")
+
+	cfg := &Config{
+		Local: LocalStore{Type: "oci", Path: filepath.Join(t.TempDir(), "oci")},
+		Remotes: []RemoteStore{{
+			Name:       "test",
+			Type:       "registry",
+			Registry:   target.Registry,
+			Repository: target.Repository,
+			PlainHTTP:  true,
+			Auth:       AuthConfig{Username: "sori", Password: "secret"},
+		}},
+	}
+	client := cfg.NewClient()
+	pkg, err := client.PackageVolume(ctx, PackageRequest{SourceDir: "./test-vol", DisplayName: "Pushed", Tag: "push.v1"})
+	if err != nil {
+		t.Fatalf("PackageVolume: %v", err)
+	}
+
+	res, err := client.PushToRemote(ctx, pkg, "test")
+	if err != nil {
+		t.Fatalf("PushToRemote: %v", err)
+	}
+	if res.ManifestDigest != pkg.ManifestDigest {
+		t.Fatalf("manifest digest: got %q want %q", res.ManifestDigest, pkg.ManifestDigest)
+	}
+	desc, err := reg.store.Resolve(ctx, "push.v1")
+	if err != nil {
+		t.Fatalf("registry missing pushed tag: %v", err)
+	}
+	if desc.Digest.String() != pkg.ManifestDigest {
+		t.Fatalf("registry tag digest: got %s want %s", desc.Digest, pkg.ManifestDigest)
+	}
+
+	cfg.Remotes[0].Auth.Password = "wrong"
+	_, err = client.PushToRemote(ctx, pkg, "test")
+	var serr *Error
+	if !errors.Is(err, ErrAuth) || !errors.As(err, &serr) || serr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected ErrAuth with status 401 for bad credentials, got %v", err)
+	}
+
+	if _, err := client.PushToRemote(ctx, pkg, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for unknown remote, got %v", err)
+	}
+	if _, err := NewClient().PushToRemote(ctx, pkg, "test"); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected ErrValidation without config, got %v", err)
+	}
+}
+
+func TestClientPushToRemote_CredentialStoreFile(t *testing.T) {
+	ctx := context.Background()
+	reg := newTestRegistry(t, filepath.Join(t.TempDir(), "registry"))
+	reg.username, reg.password = "robot", "from-file"
+	target := reg.target("data/ref")
+
+	authFile := filepath.Join(t.TempDir(), "auth.json")
+	authJSON := `{"auths":{"` + target.Registry + `":{"auth":"` + base64.StdEncoding.EncodeToString([]byte("robot:from-file")) + `"}}}`
+	if err := os.WriteFile(authFile, []byte(authJSON), 0o600); err != nil {
+		t.Fatalf("write auth file: %v", err)
+	}
+
+	cfg := &Config{
+		Local: LocalStore{Type: "oci", Path: filepath.Join(t.TempDir(), "oci")},
+		Remotes: []RemoteStore{{
+			Name:       "test",
+			Type:       "registry",
+			Registry:   target.Registry,
+			Repository: target.Repository,
+			PlainHTTP:  true,
+			Auth:       AuthConfig{CredentialStore: "file", ConfigFile: authFile},
+		}},
+	}
+	client := cfg.NewClient()
+	pkg, err := client.PackageVolume(ctx, PackageRequest{SourceDir: "./test-vol", DisplayName: "Pushed", Tag: "push.v1"})
+	if err != nil {
+		t.Fatalf("PackageVolume: %v", err)
+	}
+	if _, err := client.PushToRemote(ctx, pkg, "test"); err != nil {
+		t.Fatalf("PushToRemote: %v", err)
+	}
+
+	cfg.Remotes[0].Auth.Password = "static"
+	if _, err := cfg.RemoteTarget("test"); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected ErrValidation when mixing credential sources, got %v", err)
+	}
+}
@@ -0,0 +1,267 @@
+package sori
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	godigest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/oci"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry"
+)
+
+const (
+	mediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+// storeTempDir is the directory in the local OCI store where packaging writes
+// layer archives before they are pushed. It is owned by sori, not by the OCI
+// store, so GarbageCollect can clear archives an interrupted run left behind.
+const storeTempDir = "sori-tmp"
+
+// GCResult reports what Client.GarbageCollect removed from the local store,
+// or would remove in dry-run mode.
+type GCResult struct {
+	DryRun bool `json:"dry_run"`
+	// ReachableBlobs is the number of blobs kept because a tag or a referrer
+	// of a tagged manifest reaches them.
+	ReachableBlobs int `json:"reachable_blobs"`
+	// RemovedBlobs lists the digests of unreachable blobs, sorted.
+	RemovedBlobs []string `json:"removed_blobs"`
+	// RemovedTempFiles is the number of stale packaging archives left in
+	// the store's temporary directory.
+	RemovedTempFiles int `json:"removed_temp_files"`
+	// ReclaimedBytes is the on-disk size of RemovedBlobs and the removed
+	// temporary files.
+	ReclaimedBytes int64 `json:"reclaimed_bytes"`
+}
+
+// GarbageCollect removes blobs in the local OCI store that no tag reaches.
+// Every tagged manifest is a root; its config, layers, and child manifests are
+// kept, and so are referrers whose subject is kept. Untagged manifests left
+// behind by repackaging, and the layers only they use, are removed, together
+// with packaging archives an interrupted run left in the store.
+//
+// With opts.DryRun nothing is deleted and the result reports what would be.
+// GarbageCollect must not run while another process writes to the same store.
+func (c *Client) GarbageCollect(ctx context.Context, opts GCOptions) (*GCResult, error) {
+	const op = "Client.GarbageCollect"
+	store, err := c.openLocalStore(op)
+	if err != nil {
+		return nil, err
+	}
+	reachable, err := markReachable(ctx, op, store)
+	if err != nil {
+		return nil, err
+	}
+
+	blobs, err := listStoreBlobs(c.localStorePath)
+	if err != nil {
+		return nil, transportError(op, "list blobs", err)
+	}
+	res := &GCResult{DryRun: opts.DryRun, ReachableBlobs: len(reachable), RemovedBlobs: []string{}}
+	var garbage []string
+	for dgst, path := range blobs {
+		if _, ok := reachable[dgst]; ok {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, transportError(op, fmt.Sprintf("stat blob %s", dgst), err)
+		}
+		res.RemovedBlobs = append(res.RemovedBlobs, dgst.String())
+		res.ReclaimedBytes += info.Size()
+		garbage = append(garbage, path)
+	}
+	sort.Strings(res.RemovedBlobs)
+	tempFiles, err := listStoreTempFiles(c.localStorePath)
+	if err != nil {
+		return nil, transportError(op, "list temporary files", err)
+	}
+	for _, tf := range tempFiles {
+		res.RemovedTempFiles++
+		res.ReclaimedBytes += tf.size
+	}
+	if opts.DryRun {
+		return res, nil
+	}
+
+	// Untagged manifests are still listed in index.json. Delete them through
+	// the store so the index is rewritten; AutoGC is off because the sweep
+	// below already knows exactly which blobs are unreachable.
+	indexed, err := readIndexManifests(c.localStorePath)
+	if err != nil {
+		return nil, transportError(op, "read index.json", err)
+	}
+	store.AutoGC = false
+	for _, desc := range indexed {
+		if _, ok := reachable[desc.Digest]; ok {
+			continue
+		}
+		if err := store.Delete(ctx, desc); err != nil && !errors.Is(err, errdef.ErrNotFound) {
+			return nil, transportError(op, fmt.Sprintf("delete manifest %s", desc.Digest), err)
+		}
+	}
+	for _, path := range garbage {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, transportError(op, fmt.Sprintf("remove blob %s", path), err)
+		}
+	}
+	for _, tf := range tempFiles {
+		if err := os.RemoveAll(tf.path); err != nil {
+			return nil, transportError(op, fmt.Sprintf("remove temporary file %s", tf.path), err)
+		}
+	}
+	// Drop packaging cache entries that point at the layers just removed.
+	if _, err := os.Stat(filepath.Join(c.localStorePath, packageCacheFile)); err == nil {
+		if err := loadPackageCache(c.localStorePath).save(); err != nil {
+			return nil, transportError(op, "prune packaging cache", err)
+		}
+	}
+	Log.Infof("garbage collected %d blobs and %d temporary files (%d bytes) from %s", len(res.RemovedBlobs), res.RemovedTempFiles, res.ReclaimedBytes, c.localStorePath)
+	return res, nil
+}
+
+// openLocalStore opens the client's local OCI store, failing with ErrNotFound
+// instead of creating it when the path does not exist.
+func (c *Client) openLocalStore(op string) (*oci.Store, error) {
+	if _, err := os.Stat(c.localStorePath); err != nil {
+		if os.IsNotExist(err) {
+			return nil, notFoundError(op, fmt.Sprintf("local store %s", c.localStorePath), err)
+		}
+		return nil, transportError(op, fmt.Sprintf("stat local store %s", c.localStorePath), err)
+	}
+	store, err := oci.New(c.localStorePath)
+	if err != nil {
+		return nil, transportError(op, "open OCI store", err)
+	}
+	return store, nil
+}
+
+// markReachable walks the store graph from every tag and returns the digests
+// of all blobs it reaches, including referrers of reached manifests.
+func markReachable(ctx context.Context, op string, store *oci.Store) (map[godigest.Digest]struct{}, error) {
+	var queue []ocispec.Descriptor
+	err := store.Tags(ctx, "", func(tags []string) error {
+		for _, tag := range tags {
+			desc, err := store.Resolve(ctx, tag)
+			if err != nil {
+				return err
+			}
+			queue = append(queue, desc)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, transportError(op, "list local tags", err)
+	}
+
+	reachable := make(map[godigest.Digest]struct{})
+	for len(queue) > 0 {
+		desc := queue[0]
+		queue = queue[1:]
+		if _, ok := reachable[desc.Digest]; ok {
+			continue
+		}
+		reachable[desc.Digest] = struct{}{}
+		if !isManifestMediaType(desc.MediaType) {
+			continue
+		}
+		successors, err := content.Successors(ctx, store, desc)
+		if err != nil {
+			return nil, integrityError(op, fmt.Sprintf("read manifest %s", desc.Digest), err)
+		}
+		referrers, err := registry.Referrers(ctx, store, desc, "")
+		if err != nil {
+			return nil, transportError(op, fmt.Sprintf("list referrers of %s", desc.Digest), err)
+		}
+		queue = append(queue, successors...)
+		queue = append(queue, referrers...)
+	}
+	return reachable, nil
+}
+
+func isManifestMediaType(mediaType string) bool {
+	switch mediaType {
+	case ocispec.MediaTypeImageManifest, ocispec.MediaTypeImageIndex, mediaTypeDockerManifest, mediaTypeDockerManifestList:
+		return true
+	default:
+		return false
+	}
+}
+
+// listStoreBlobs maps every blob file under root/blobs to its digest. Files
+// that are not valid digests are left alone.
+func listStoreBlobs(root string) (map[godigest.Digest]string, error) {
+	blobsDir := filepath.Join(root, ocispec.ImageBlobsDir)
+	algDirs, err := os.ReadDir(blobsDir)
+	if err != nil {
+		return nil, err
+	}
+	blobs := make(map[godigest.Digest]string)
+	for _, algDir := range algDirs {
+		if !algDir.IsDir() || !godigest.Algorithm(algDir.Name()).Available() {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(blobsDir, algDir.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			dgst := godigest.NewDigestFromEncoded(godigest.Algorithm(algDir.Name()), entry.Name())
+			if entry.IsDir() || dgst.Validate() != nil {
+				continue
+			}
+			blobs[dgst] = filepath.Join(blobsDir, algDir.Name(), entry.Name())
+		}
+	}
+	return blobs, nil
+}
+
+type storeTempFile struct {
+	path string
+	size int64
+}
+
+// listStoreTempFiles returns every entry of the store's temporary directory.
+// GarbageCollect never runs next to a writer, so all of them are stale.
+func listStoreTempFiles(root string) ([]storeTempFile, error) {
+	dir := filepath.Join(root, storeTempDir)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	files := make([]storeTempFile, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, storeTempFile{path: filepath.Join(dir, entry.Name()), size: info.Size()})
+	}
+	return files, nil
+}
+
+// readIndexManifests returns the manifest descriptors listed in index.json.
+func readIndexManifests(root string) ([]ocispec.Descriptor, error) {
+	data, err := os.ReadFile(filepath.Join(root, ocispec.ImageIndexFile))
+	if err != nil {
+		return nil, err
+	}
+	var index ocispec.Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, err
+	}
+	return index.Manifests, nil
+}
@@ -0,0 +1,110 @@
+package sori
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content/oci"
+)
+
+func TestClientGarbageCollect(t *testing.T) {
+	ctx := context.Background()
+	storePath := filepath.Join(t.TempDir(), "oci")
+	src := filepath.Join(t.TempDir(), "vol")
+	client := NewClient(WithLocalStorePath(storePath))
+
+	writeTestVolume(t, src, "first")
+	first, err := client.PackageVolume(ctx, PackageRequest{SourceDir: src, DisplayName: "GC", Tag: "gc.v1"})
+	if err != nil {
+		t.Fatalf("PackageVolume first: %v", err)
+	}
+	writeTestVolume(t, src, "second")
+	second, err := client.PackageVolume(ctx, PackageRequest{SourceDir: src, DisplayName: "GC", Tag: "gc.v1"})
+	if err != nil {
+		t.Fatalf("PackageVolume second: %v", err)
+	}
+	if first.ManifestDigest == second.ManifestDigest {
+		t.Fatal("expected repackaging to produce a new manifest")
+	}
+
+	store, err := oci.New(storePath)
+	if err != nil {
+		t.Fatalf("oci.New: %v", err)
+	}
+	referrer, err := PushDataSpecReferrer(ctx, store, second.ManifestDigest, []byte(`{"name":"gc"}`))
+	if err != nil {
+		t.Fatalf("PushDataSpecReferrer: %v", err)
+	}
+	blobPath := func(dgst string) string {
+		return filepath.Join(storePath, ocispec.ImageBlobsDir, "sha256", dgst[len("sha256:"):])
+	}
+	stale := filepath.Join(storePath, storeTempDir, "sori-layer-stale")
+	if err := os.WriteFile(stale, []byte("interrupted archive"), 0o644); err != nil {
+		t.Fatalf("write stale temp file: %v", err)
+	}
+
+	dry, err := client.GarbageCollect(ctx, GCOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("GarbageCollect dry run: %v", err)
+	}
+	if !dry.DryRun || len(dry.RemovedBlobs) == 0 || dry.ReclaimedBytes <= 0 {
+		t.Fatalf("expected reclaimable blobs in dry run, got %+v", dry)
+	}
+	if dry.RemovedTempFiles != 1 {
+		t.Fatalf("expected the stale temp file in dry run, got %+v", dry)
+	}
+	if _, err := os.Stat(blobPath(first.ManifestDigest)); err != nil {
+		t.Fatalf("dry run must not delete blobs: %v", err)
+	}
+	if _, err := os.Stat(stale); err != nil {
+		t.Fatalf("dry run must not delete temp files: %v", err)
+	}
+
+	res, err := client.GarbageCollect(ctx, GCOptions{})
+	if err != nil {
+		t.Fatalf("GarbageCollect: %v", err)
+	}
+	if len(res.RemovedBlobs) != len(dry.RemovedBlobs) || res.ReclaimedBytes != dry.ReclaimedBytes {
+		t.Fatalf("dry run %+v does not match collection %+v", dry, res)
+	}
+	if _, err := os.Stat(blobPath(first.ManifestDigest)); !os.IsNotExist(err) {
+		t.Fatalf("expected old manifest to be removed, got %v", err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Fatalf("expected stale temp file to be removed, got %v", err)
+	}
+	for _, dgst := range []string{second.ManifestDigest, referrer.ReferrerDigest} {
+		if _, err := os.Stat(blobPath(dgst)); err != nil {
+			t.Fatalf("expected %s to be kept: %v", dgst, err)
+		}
+	}
+
+	if _, err := oci.New(storePath); err != nil {
+		t.Fatalf("store must reopen after collection: %v", err)
+	}
+	dest := filepath.Join(t.TempDir(), "restored")
+	if _, err := client.FetchVolume(ctx, dest, storePath, "gc.v1", FetchOptions{}); err != nil {
+		t.Fatalf("FetchVolume after collection: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dest, "vol", "a", "a.txt")); err != nil || string(data) != "second" {
+		t.Fatalf("restored payload: %q, %v", data, err)
+	}
+
+	again, err := client.GarbageCollect(ctx, GCOptions{})
+	if err != nil {
+		t.Fatalf("GarbageCollect again: %v", err)
+	}
+	if len(again.RemovedBlobs) != 0 || again.RemovedTempFiles != 0 {
+		t.Fatalf("expected nothing left to collect, got %v", again.RemovedBlobs)
+	}
+}
+
+func TestClientGarbageCollect_MissingStore(t *testing.T) {
+	client := NewClient(WithLocalStorePath(filepath.Join(t.TempDir(), "none")))
+	if _, err := client.GarbageCollect(context.Background(), GCOptions{DryRun: true}); !isKind(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package sori
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	godigest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// LocalArtifact summarizes one tag in the local OCI store.
+type LocalArtifact struct {
+	Tag          string `json:"tag"`
+	Digest       string `json:"digest"`
+	ArtifactType string `json:"artifact_type,omitempty"`
+	// Size is the manifest, config, and layer bytes the tag references.
+	Size      int64  `json:"size"`
+	CreatedAt string `json:"created_at,omitempty"`
+	// Partitions counts layers that carry a partition path annotation.
+	Partitions int `json:"partitions"`
+}
+
+// ListLocal lists the tags in the client's local OCI store, sorted by tag.
+func (c *Client) ListLocal(ctx context.Context) ([]LocalArtifact, error) {
+	const op = "Client.ListLocal"
+	store, err := c.openLocalStore(op)
+	if err != nil {
+		return nil, err
+	}
+	var tags []string
+	if err := store.Tags(ctx, "", func(page []string) error {
+		tags = append(tags, page...)
+		return nil
+	}); err != nil {
+		return nil, transportError(op, "list local tags", err)
+	}
+	sort.Strings(tags)
+
+	artifacts := make([]LocalArtifact, 0, len(tags))
+	for _, tag := range tags {
+		desc, manifest, err := fetchManifest(ctx, op, store, tag)
+		if err != nil {
+			return nil, err
+		}
+		artifact := LocalArtifact{
+			Tag:          tag,
+			Digest:       desc.Digest.String(),
+			ArtifactType: manifest.ArtifactType,
+			Size:         manifestTotalSize(desc, manifest),
+			CreatedAt:    manifest.Annotations[ocispec.AnnotationCreated],
+		}
+		for _, layer := range manifest.Layers {
+			if layer.Annotations[annotationPartitionPath] != "" {
+				artifact.Partitions++
+			}
+		}
+		artifacts = append(artifacts, artifact)
+	}
+	return artifacts, nil
+}
+
+// InspectLocal returns the manifest, config blob, and partition layout of ref,
+// a tag or manifest digest in the client's local OCI store.
+func (c *Client) InspectLocal(ctx context.Context, ref string) (*Inspection, error) {
+	const op = "Client.InspectLocal"
+	if strings.TrimSpace(ref) == "" {
+		return nil, validationError(op, "reference is required", nil)
+	}
+	store, err := c.openLocalStore(op)
+	if err != nil {
+		return nil, err
+	}
+	return inspectArtifact(ctx, op, store, ref)
+}
+
+// Untag removes tag from the client's local OCI store. The manifest stays in
+// the store until GarbageCollect finds it unreachable.
+func (c *Client) Untag(ctx context.Context, tag string) error {
+	const op = "Client.Untag"
+	if strings.TrimSpace(tag) == "" {
+		return validationError(op, "tag is required", nil)
+	}
+	if _, err := godigest.Parse(tag); err == nil {
+		return validationError(op, fmt.Sprintf("%q is a digest, not a tag; use Delete", tag), nil)
+	}
+	store, err := c.openLocalStore(op)
+	if err != nil {
+		return err
+	}
+	if err := store.Untag(ctx, tag); err != nil {
+		return registryError(op, fmt.Sprintf("untag %q", tag), err)
+	}
+	return nil
+}
+
+// Delete removes the manifest that ref, a tag or digest, resolves to from the
+// client's local OCI store, together with every tag pointing at it, its
+// referrers, and the blobs no other manifest uses.
+func (c *Client) Delete(ctx context.Context, ref string) error {
+	const op = "Client.Delete"
+	if strings.TrimSpace(ref) == "" {
+		return validationError(op, "reference is required", nil)
+	}
+	store, err := c.openLocalStore(op)
+	if err != nil {
+		return err
+	}
+	desc, err := store.Resolve(ctx, ref)
+	if err != nil {
+		return registryError(op, fmt.Sprintf("resolve %q", ref), err)
+	}
+	if err := store.Delete(ctx, desc); err != nil {
+		return registryError(op, fmt.Sprintf("delete %s", desc.Digest), err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package sori
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestClientListInspectLocal(t *testing.T) {
+	ctx := context.Background()
+	client := NewClient(WithLocalStorePath(filepath.Join(t.TempDir(), "oci")))
+	src := filepath.Join(t.TempDir(), "vol")
+	writeTestVolume(t, src, "payload")
+
+	b, err := client.PackageVolume(ctx, PackageRequest{SourceDir: src, DisplayName: "Bravo", Tag: "b.v1", Annotations: map[string]string{"env": "test"}})
+	if err != nil {
+		t.Fatalf("PackageVolume b: %v", err)
+	}
+	a, err := client.PackageVolume(ctx, PackageRequest{SourceDir: src, DisplayName: "Alpha", Tag: "a.v1"})
+	if err != nil {
+		t.Fatalf("PackageVolume a: %v", err)
+	}
+
+	artifacts, err := client.ListLocal(ctx)
+	if err != nil {
+		t.Fatalf("ListLocal: %v", err)
+	}
+	if len(artifacts) != 2 || artifacts[0].Tag != "a.v1" || artifacts[1].Tag != "b.v1" {
+		t.Fatalf("expected a.v1 and b.v1 sorted, got %+v", artifacts)
+	}
+	got := artifacts[1]
+	if got.Digest != b.ManifestDigest || got.ArtifactType != ArtifactTypeVolume || got.Partitions != len(b.Partitions) {
+		t.Fatalf("unexpected artifact: %+v", got)
+	}
+	if got.CreatedAt == "" || got.Size <= b.TotalSize {
+		t.Fatalf("expected created time and size above layer total %d, got %+v", b.TotalSize, got)
+	}
+
+	inspected, err := client.InspectLocal(ctx, "b.v1")
+	if err != nil {
+		t.Fatalf("InspectLocal: %v", err)
+	}
+	if inspected.Digest != b.ManifestDigest || inspected.Manifest.Annotations["env"] != "test" {
+		t.Fatalf("unexpected inspection: %+v", inspected)
+	}
+	if string(inspected.Config) != "{}" {
+		t.Fatalf("expected config blob {}, got %q", inspected.Config)
+	}
+	if inspected.Volume == nil || inspected.Volume.DisplayName != "Bravo" || len(inspected.Volume.Partitions) != len(b.Partitions) {
+		t.Fatalf("unexpected volume view: %+v", inspected.Volume)
+	}
+	for i, p := range inspected.Volume.Partitions {
+		if p.Path != b.Partitions[i].Path || p.ManifestRef != b.Partitions[i].ManifestRef || p.Compression != CompressionGzip {
+			t.Fatalf("partition %d: got %+v want %+v", i, p, b.Partitions[i])
+		}
+	}
+
+	byDigest, err := client.InspectLocal(ctx, a.ManifestDigest)
+	if err != nil || byDigest.Volume.DisplayName != "Alpha" {
+		t.Fatalf("InspectLocal by digest: %+v, %v", byDigest, err)
+	}
+	if _, err := client.InspectLocal(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestClientUntagDelete(t *testing.T) {
+	ctx := context.Background()
+	storePath := filepath.Join(t.TempDir(), "oci")
+	client := NewClient(WithLocalStorePath(storePath))
+	src := filepath.Join(t.TempDir(), "vol")
+	writeTestVolume(t, src, "payload")
+	pkg, err := client.PackageVolume(ctx, PackageRequest{SourceDir: src, DisplayName: "Del", Tag: "del.v1"})
+	if err != nil {
+		t.Fatalf("PackageVolume: %v", err)
+	}
+
+	if err := client.Untag(ctx, pkg.ManifestDigest); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected ErrValidation untagging a digest, got %v", err)
+	}
+	if err := client.Untag(ctx, "del.v1"); err != nil {
+		t.Fatalf("Untag: %v", err)
+	}
+	if err := client.Untag(ctx, "del.v1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound untagging twice, got %v", err)
+	}
+	if artifacts, err := client.ListLocal(ctx); err != nil || len(artifacts) != 0 {
+		t.Fatalf("expected no tags after untag, got %+v, %v", artifacts, err)
+	}
+
+	if err := client.Delete(ctx, pkg.ManifestDigest); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	for _, dgst := range []string{pkg.ManifestDigest, pkg.Partitions[0].ManifestRef} {
+		path := filepath.Join(storePath, ocispec.ImageBlobsDir, "sha256", dgst[len("sha256:"):])
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be deleted, got %v", dgst, err)
+		}
+	}
+	if err := client.Delete(ctx, pkg.ManifestDigest); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound deleting twice, got %v", err)
+	}
+}
@@ -2,11 +2,14 @@ package sori
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"strings"
 	"sync"
 
 	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/seoyhaein/sori/archiveutil"
 	"github.com/seoyhaein/sori/registryutil"
 	"oras.land/oras-go/v2/registry/remote"
 	"oras.land/oras-go/v2/registry/remote/auth"
@@ -23,6 +26,10 @@ type (
 		ManifestRef string `json:"manifest_ref"`
 		CreatedAt   string `json:"created_at"`
 		Compression string `json:"compression"`
+		// CacheStatus is CacheHit or CacheMiss for partitions fetched through
+		// the pull-through cache, CacheReused for partitions an incremental
+		// fetch kept, and empty otherwise.
+		CacheStatus string `json:"cache_status,omitempty"`
 	}
 	// VolumeIndex describes the partition layout of a packaged dataset.
 	//
@@ -32,6 +39,7 @@ type (
 		VolumeRef   string      `json:"volume_ref"`
 		DisplayName string      `json:"display_name"`
 		CreatedAt   string      `json:"created_at"`
+		Layout      string      `json:"layout,omitempty"`
 		Partitions  []Partition `json:"partitions"`
 	}
 	ConfigBlob  map[string]interface{}
@@ -109,10 +117,42 @@ type (
 	}
 )
 
+const (
+	// PartitionLayoutNested archives every partition with its whole subtree,
+	// so a parent layer also carries its child partitions' files. It is the
+	// default layout.
+	PartitionLayoutNested = "nested"
+	// PartitionLayoutExclusive archives only the files a partition owns,
+	// leaving child partitions out, and adds a root partition for top-level
+	// files. Layer bytes then scale with dataset size rather than depth.
+	PartitionLayoutExclusive = "exclusive"
+)
+
+const (
+	// ArtifactTypeVolume is the OCI artifactType recorded on volume manifests.
+	ArtifactTypeVolume = "application/vnd.sori.volume.v1"
+	// MediaTypeVolumeConfig is the media type of the volume config blob.
+	// Volumes packaged before it existed use ocispec.MediaTypeImageConfig.
+	MediaTypeVolumeConfig = "application/vnd.sori.volume.config.v1+json"
+	// MediaTypeVolumeFiles is the artifact type of the referrer attached to
+	// every packaged volume manifest, and the media type of its single layer,
+	// which lists every packaged file with its size and sha256.
+	MediaTypeVolumeFiles = "application/vnd.sori.volume.files.v1+json"
+	// mediaTypeVolumeFilesConfig is the config media type of the file
+	// manifest referrer.
+	mediaTypeVolumeFilesConfig = "application/vnd.sori.volume.files.config.v1+json"
+)
+
+const (
+	annotationPartitionPath   = "org.example.partitionPath"
+	annotationPartitionLayout = "org.example.partitionLayout"
+)
+
 const (
 	ConfigBlobJson  = "configblob.json"
 	CollectionJson  = "volume-collection.json"
 	VolumeIndexJson = "volume-index.json"
+	VolumeFilesJson = "volume-files.json"
 )
 
 // Deprecated: prefer Config.NewClient followed by Client.PackageVolume or
@@ -124,10 +164,10 @@ func PackageVolume(ctx context.Context, req PackageRequest) (*PackageResult, err
 // PackageVolumeToStore packages a dataset into the given local OCI store using
 // the preferred core packaging contract.
 func PackageVolumeToStore(ctx context.Context, localStorePath string, req PackageRequest) (*PackageResult, error) {
-	return packageVolumeToStoreWithOptions(ctx, localStorePath, req, PackageOptions{ConfigBlob: req.ConfigBlob})
+	return packageVolumeToStoreWithOptions(ctx, localStorePath, req, PackageOptions{ConfigBlob: req.ConfigBlob}, nil)
 }
 
-func packageVolumeToStoreWithOptions(ctx context.Context, localStorePath string, req PackageRequest, opts PackageOptions) (*PackageResult, error) {
+func packageVolumeToStoreWithOptions(ctx context.Context, localStorePath string, req PackageRequest, opts PackageOptions, progress *progressSink) (*PackageResult, error) {
 	if strings.TrimSpace(localStorePath) == "" {
 		return nil, validationError("PackageVolumeToStore", "local store path is required", nil)
 	}
@@ -161,12 +201,37 @@ func packageVolumeToStoreWithOptions(ctx context.Context, localStorePath string,
 		configBlob = append([]byte(nil), req.ConfigBlob...)
 	}
 
+	switch opts.PartitionLayout {
+	case "", PartitionLayoutNested, PartitionLayoutExclusive:
+	default:
+		return nil, validationError("PackageVolumeToStore", fmt.Sprintf("unknown partition layout %q", opts.PartitionLayout), nil)
+	}
+	compression, err := archiveutil.ParseCompression(opts.Compression)
+	if err != nil {
+		return nil, validationError("PackageVolumeToStore", fmt.Sprintf("invalid compression %q", opts.Compression), err)
+	}
+	partitionCompression := make(map[string]archiveutil.Compression, len(opts.PartitionCompression))
+	for partPath, spec := range opts.PartitionCompression {
+		c, err := archiveutil.ParseCompression(spec)
+		if err != nil {
+			return nil, validationError("PackageVolumeToStore", fmt.Sprintf("invalid compression %q for partition %q", spec, partPath), err)
+		}
+		partitionCompression[partPath] = c
+	}
+
 	vi, err := GenerateVolumeIndex(req.SourceDir, req.DisplayName)
 	if err != nil {
 		return nil, transportError("PackageVolumeToStore", "generate volume index", err)
 	}
 
-	published, err := vi.publishVolumeToStore(ctx, localStorePath, req.SourceDir, req.Tag, configBlob)
+	published, err := vi.publishVolumeToStore(ctx, localStorePath, req.SourceDir, req.Tag, configBlob, publishOptions{
+		layout:               opts.PartitionLayout,
+		annotations:          packageManifestAnnotations(req),
+		compression:          compression,
+		partitionCompression: partitionCompression,
+		progress:             progress,
+		fullVerification:     opts.FullVerification,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -191,6 +256,10 @@ func packageVolumeToStoreWithOptions(ctx context.Context, localStorePath string,
 // PushPackagedVolume copies a packaged artifact from the local OCI store to a
 // remote registry using the preferred core push contract.
 func PushPackagedVolume(ctx context.Context, localStorePath string, pkg *PackageResult, target RemoteTarget) (*PushResult, error) {
+	return pushPackagedVolume(ctx, localStorePath, pkg, target, nil)
+}
+
+func pushPackagedVolume(ctx context.Context, localStorePath string, pkg *PackageResult, target RemoteTarget, progress *progressSink) (*PushResult, error) {
 	if pkg == nil {
 		return nil, validationError("PushPackagedVolume", "package result is required", nil)
 	}
@@ -204,12 +273,12 @@ func PushPackagedVolume(ctx context.Context, localStorePath string, pkg *Package
 		return nil, validationError("PushPackagedVolume", "remote target repository is required", nil)
 	}
 
-	remoteRepo := strings.TrimRight(target.Registry, "/") + "/" + strings.TrimLeft(target.Repository, "/")
+	remoteRepo := remoteRepositoryRef(target)
 	repo, err := newRemoteRepository(remoteRepo, target)
 	if err != nil {
 		return nil, err
 	}
-	return pushLocalTagToRepository(ctx, localStorePath, pkg.LocalTag, repo)
+	return pushLocalTagToRepository(ctx, localStorePath, pkg.LocalTag, repo, progress)
 }
 
 func deriveStableRef(req PackageRequest) string {
@@ -228,6 +297,28 @@ func deriveStableRef(req PackageRequest) string {
 	}
 }
 
+// packageManifestAnnotations maps the request identity onto the standard
+// org.opencontainers.image.* manifest annotations. Caller annotations are
+// applied last and win over the derived values. The source annotation is
+// never derived: SourceDir is a local path that means nothing to a consumer,
+// so it is only set when the caller passes it in Annotations.
+func packageManifestAnnotations(req PackageRequest) map[string]string {
+	out := make(map[string]string, len(req.Annotations)+4)
+	set := func(key, value string) {
+		if v := strings.TrimSpace(value); v != "" {
+			out[key] = v
+		}
+	}
+	set(ocispec.AnnotationTitle, req.DisplayName)
+	set(ocispec.AnnotationVersion, req.Version)
+	set(ocispec.AnnotationDescription, req.Description)
+	set(ocispec.AnnotationRefName, deriveStableRef(req))
+	for k, v := range req.Annotations {
+		out[k] = v
+	}
+	return out
+}
+
 func cloneAnnotations(src map[string]string) map[string]string {
 	if len(src) == 0 {
 		return nil
@@ -239,6 +330,10 @@ func cloneAnnotations(src map[string]string) map[string]string {
 	return out
 }
 
+func remoteRepositoryRef(target RemoteTarget) string {
+	return strings.TrimRight(target.Registry, "/") + "/" + strings.TrimLeft(target.Repository, "/")
+}
+
 func newRemoteRepository(remoteRepo string, target RemoteTarget) (*remote.Repository, error) {
 	return registryutil.NewRepository(remoteRepo, registryutil.RemoteConfig{
 		PlainHTTP:           target.PlainHTTP,
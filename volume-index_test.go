@@ -8,8 +8,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/stretchr/testify/assert"
+	"io"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
 	"oras.land/oras-go/v2/content/oci"
 	"os"
 	"path/filepath"
@@ -857,3 +861,362 @@ func TestUntarGzDir_RejectsPathTraversal(t *testing.T) {
 		t.Fatalf("expected path traversal error, got %v", err)
 	}
 }
+
+func TestPackageVolumeToStore_ExclusiveLayout(t *testing.T) {
+	ctx := context.Background()
+	tmp := t.TempDir()
+	volDir := filepath.Join(tmp, "vol")
+	files := map[string]string{
+		"README.md":         "root",
+		"a/a.txt":           "a",
+		"a/b/b.txt":         "b",
+		"a/b/c/c.txt":       "c",
+		"other/other.txt":   "other",
+		ConfigBlobJson:      "{}",
+		"a/b/c/deep/d.txt":  "d",
+		"other/nested/n.md": "n",
+	}
+	for rel, data := range files {
+		path := filepath.Join(volDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("MkdirAll: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+
+	storePath := filepath.Join(tmp, "oci")
+	client := NewClient(WithLocalStorePath(storePath))
+	pkg, err := client.PackageVolumeWithOptions(ctx, PackageRequest{
+		SourceDir:   volDir,
+		DisplayName: "Exclusive",
+		Tag:         "exclusive.v1",
+	}, PackageOptions{PartitionLayout: PartitionLayoutExclusive})
+	if err != nil {
+		t.Fatalf("PackageVolumeWithOptions: %v", err)
+	}
+	if pkg.VolumeIndex.Layout != PartitionLayoutExclusive {
+		t.Fatalf("layout mismatch: got %q", pkg.VolumeIndex.Layout)
+	}
+	if pkg.Partitions[0].Path != "vol" {
+		t.Fatalf("expected root partition first, got %q", pkg.Partitions[0].Path)
+	}
+
+	store, err := oci.New(storePath)
+	if err != nil {
+		t.Fatalf("oci.New: %v", err)
+	}
+	seen := make(map[string]string)
+	for _, part := range pkg.Partitions {
+		rc, err := store.Fetch(ctx, ocispec.Descriptor{Digest: digest.Digest(part.ManifestRef)})
+		if err != nil {
+			t.Fatalf("fetch layer %s: %v", part.Path, err)
+		}
+		gz, err := gzip.NewReader(rc)
+		if err != nil {
+			t.Fatalf("gzip reader: %v", err)
+		}
+		tr := tar.NewReader(gz)
+		for {
+			hdr, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				t.Fatalf("read tar: %v", err)
+			}
+			if hdr.Typeflag != tar.TypeReg {
+				continue
+			}
+			if owner, dup := seen[hdr.Name]; dup {
+				t.Fatalf("file %q stored in both %q and %q", hdr.Name, owner, part.Path)
+			}
+			seen[hdr.Name] = part.Path
+		}
+		rc.Close()
+	}
+	if len(seen) != len(files) {
+		t.Fatalf("expected %d archived files, got %d: %v", len(files), len(seen), seen)
+	}
+
+	dest := filepath.Join(tmp, "restored")
+	fetched, err := client.FetchVolume(ctx, dest, storePath, "exclusive.v1", FetchOptions{Concurrency: 3})
+	if err != nil {
+		t.Fatalf("FetchVolume: %v", err)
+	}
+	if fetched.Layout != PartitionLayoutExclusive {
+		t.Fatalf("fetched layout mismatch: got %q", fetched.Layout)
+	}
+	for rel, data := range files {
+		got, err := os.ReadFile(filepath.Join(dest, "vol", filepath.FromSlash(rel)))
+		if err != nil {
+			t.Fatalf("read restored %s: %v", rel, err)
+		}
+		if string(got) != data {
+			t.Fatalf("restored %s: got %q want %q", rel, got, data)
+		}
+	}
+}
+
+func TestClientFetchVolume_NestedLayoutConcurrentSymlinks(t *testing.T) {
+	ctx := context.Background()
+	tmp := t.TempDir()
+	volDir := filepath.Join(tmp, "vol")
+	linkDir := filepath.Join(volDir, "a", "links")
+	if err := os.MkdirAll(linkDir, 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(volDir, "a", "target.txt"), []byte("target"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	for i := 0; i < 200; i++ {
+		if err := os.Symlink("../target.txt", filepath.Join(linkDir, fmt.Sprintf("link-%03d", i))); err != nil {
+			t.Fatalf("Symlink: %v", err)
+		}
+	}
+
+	storePath := filepath.Join(tmp, "oci")
+	client := NewClient(WithLocalStorePath(storePath))
+	if _, err := client.PackageVolume(ctx, PackageRequest{SourceDir: volDir, DisplayName: "Links", Tag: "links.v1"}); err != nil {
+		t.Fatalf("PackageVolume: %v", err)
+	}
+
+	// vol/a and vol/a/links both carry the links in the nested layout, so
+	// parallel extraction creates each link twice at the same time.
+	for i := 0; i < 20; i++ {
+		dest := filepath.Join(tmp, fmt.Sprintf("restored-%d", i))
+		if _, err := client.FetchVolume(ctx, dest, storePath, "links.v1", FetchOptions{Concurrency: 4}); err != nil {
+			t.Fatalf("FetchVolume run %d: %v", i, err)
+		}
+		link, err := os.Readlink(filepath.Join(dest, "vol", "a", "links", "link-199"))
+		if err != nil || link != "../target.txt" {
+			t.Fatalf("Readlink run %d: got %q, %v", i, link, err)
+		}
+		entries, err := os.ReadDir(filepath.Join(dest, "vol", "a", "links"))
+		if err != nil || len(entries) != 200 {
+			t.Fatalf("run %d: expected 200 links without leftovers, got %d, %v", i, len(entries), err)
+		}
+	}
+}
+
+func TestClientFetchVolume_ExtractLimitsTypedError(t *testing.T) {
+	ctx := context.Background()
+	storePath := filepath.Join(t.TempDir(), "oci")
+	client := NewClient(WithLocalStorePath(storePath))
+	if _, err := client.PackageVolume(ctx, PackageRequest{
+		SourceDir:   "./test-vol",
+		DisplayName: "Limited",
+		Tag:         "limited.v1",
+	}); err != nil {
+		t.Fatalf("PackageVolume: %v", err)
+	}
+
+	_, err := client.FetchVolume(ctx, filepath.Join(t.TempDir(), "restored"), storePath, "limited.v1", FetchOptions{
+		Concurrency: 2,
+		Limits:      ExtractLimits{MaxEntries: 1},
+	})
+	if !errors.Is(err, ErrIntegrity) {
+		t.Fatalf("expected ErrIntegrity, got %v", err)
+	}
+}
+
+func TestPackageVolumeToStore_ManifestAnnotations(t *testing.T) {
+	ctx := context.Background()
+	storePath := filepath.Join(t.TempDir(), "oci")
+	req := PackageRequest{
+		SourceDir:   "./test-vol",
+		DisplayName: "Annotated Volume",
+		Tag:         "annotated.v1",
+		Dataset:     "hg38",
+		Version:     "v1",
+		Description: "annotated package",
+		Annotations: map[string]string{
+			"env":                     "test",
+			ocispec.AnnotationVersion: "v1-override",
+			ocispec.AnnotationSource:  "https://example.org/hg38",
+		},
+	}
+
+	if _, ok := packageManifestAnnotations(PackageRequest{SourceDir: req.SourceDir})[ocispec.AnnotationSource]; ok {
+		t.Fatal("source annotation must not be derived from SourceDir")
+	}
+	pkg, err := PackageVolumeToStore(ctx, storePath, req)
+	if err != nil {
+		t.Fatalf("PackageVolumeToStore: %v", err)
+	}
+	manifest := readTestManifest(t, storePath, req.Tag)
+	want := map[string]string{
+		ocispec.AnnotationTitle:       "Annotated Volume",
+		ocispec.AnnotationVersion:     "v1-override",
+		ocispec.AnnotationDescription: "annotated package",
+		ocispec.AnnotationSource:      "https://example.org/hg38",
+		ocispec.AnnotationRefName:     "hg38:v1",
+		"env":                         "test",
+	}
+	for k, v := range want {
+		if got := manifest.Annotations[k]; got != v {
+			t.Fatalf("annotation %q: got %q want %q", k, got, v)
+		}
+	}
+	if manifest.Annotations[ocispec.AnnotationCreated] == "" {
+		t.Fatal("expected created annotation")
+	}
+
+	// Same content, same annotations: the existing manifest is reused.
+	again, err := PackageVolumeToStore(ctx, storePath, req)
+	if err != nil {
+		t.Fatalf("PackageVolumeToStore again: %v", err)
+	}
+	if again.ManifestDigest != pkg.ManifestDigest {
+		t.Fatalf("expected unchanged manifest, got %q want %q", again.ManifestDigest, pkg.ManifestDigest)
+	}
+
+	// Same content, changed annotations: the manifest is rewritten.
+	req.Annotations = map[string]string{"env": "prod"}
+	changed, err := PackageVolumeToStore(ctx, storePath, req)
+	if err != nil {
+		t.Fatalf("PackageVolumeToStore changed: %v", err)
+	}
+	if changed.ManifestDigest == pkg.ManifestDigest {
+		t.Fatal("expected a new manifest after annotation change")
+	}
+	if got := readTestManifest(t, storePath, req.Tag).Annotations["env"]; got != "prod" {
+		t.Fatalf("env annotation: got %q want %q", got, "prod")
+	}
+}
+
+func readTestManifest(t *testing.T, storePath, ref string) ocispec.Manifest {
+	t.Helper()
+	ctx := context.Background()
+	store, err := oci.New(storePath)
+	if err != nil {
+		t.Fatalf("oci.New: %v", err)
+	}
+	desc, err := store.Resolve(ctx, ref)
+	if err != nil {
+		t.Fatalf("Resolve %q: %v", ref, err)
+	}
+	data, err := content.FetchAll(ctx, store, desc)
+	if err != nil {
+		t.Fatalf("FetchAll manifest: %v", err)
+	}
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("decode manifest: %v", err)
+	}
+	return manifest
+}
+
+func TestPackageVolumeToStore_VolumeArtifactType(t *testing.T) {
+	ctx := context.Background()
+	storePath := filepath.Join(t.TempDir(), "oci")
+	if _, err := PackageVolumeToStore(ctx, storePath, PackageRequest{
+		SourceDir:   "./test-vol",
+		DisplayName: "Typed Volume",
+		Tag:         "typed.v1",
+	}); err != nil {
+		t.Fatalf("PackageVolumeToStore: %v", err)
+	}
+	manifest := readTestManifest(t, storePath, "typed.v1")
+	if manifest.ArtifactType != ArtifactTypeVolume {
+		t.Fatalf("artifactType: got %q want %q", manifest.ArtifactType, ArtifactTypeVolume)
+	}
+	if manifest.Config.MediaType != MediaTypeVolumeConfig {
+		t.Fatalf("config media type: got %q want %q", manifest.Config.MediaType, MediaTypeVolumeConfig)
+	}
+
+	store, err := oci.New(storePath)
+	if err != nil {
+		t.Fatalf("oci.New: %v", err)
+	}
+	retag := func(tag, artifactType, configMediaType string) {
+		m := manifest
+		m.ArtifactType = artifactType
+		m.Config.MediaType = configMediaType
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatalf("marshal manifest: %v", err)
+		}
+		if _, err := oras.TagBytes(ctx, store, ocispec.MediaTypeImageManifest, data, tag); err != nil {
+			t.Fatalf("TagBytes %q: %v", tag, err)
+		}
+	}
+
+	// Volumes packaged before the dedicated types still fetch.
+	retag("legacy.v1", "", ocispec.MediaTypeImageConfig)
+	if _, err := FetchVolSeq(ctx, filepath.Join(t.TempDir(), "legacy"), storePath, "legacy.v1"); err != nil {
+		t.Fatalf("fetch legacy volume: %v", err)
+	}
+
+	// Other artifacts are rejected before any layer is extracted.
+	retag("foreign.v1", "application/vnd.example.other", ocispec.MediaTypeImageConfig)
+	_, err = FetchVolSeq(ctx, filepath.Join(t.TempDir(), "foreign"), storePath, "foreign.v1")
+	if !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected ErrValidation, got %v", err)
+	}
+}
+
+func TestPackageVolumeToStore_PartitionCompression(t *testing.T) {
+	ctx := context.Background()
+	storePath := filepath.Join(t.TempDir(), "oci")
+	client := NewClient(WithLocalStorePath(storePath))
+	req := PackageRequest{
+		SourceDir:   "./test-vol",
+		DisplayName: "Compressed",
+		Tag:         "compressed.v1",
+	}
+	base, err := client.PackageVolume(ctx, req)
+	if err != nil {
+		t.Fatalf("PackageVolume: %v", err)
+	}
+	if len(base.Partitions) < 2 {
+		t.Fatalf("expected at least two partitions, got %d", len(base.Partitions))
+	}
+	rawPath := base.Partitions[0].Path
+
+	pkg, err := client.PackageVolumeWithOptions(ctx, req, PackageOptions{
+		Compression:          "zstd:9",
+		PartitionCompression: map[string]string{rawPath: CompressionNone},
+	})
+	if err != nil {
+		t.Fatalf("PackageVolumeWithOptions: %v", err)
+	}
+	wantCompression := func(path string) string {
+		if path == rawPath {
+			return CompressionNone
+		}
+		return CompressionZstd
+	}
+	for _, p := range pkg.Partitions {
+		if p.Compression != wantCompression(p.Path) {
+			t.Fatalf("partition %q compression: got %q want %q", p.Path, p.Compression, wantCompression(p.Path))
+		}
+	}
+	for _, layer := range readTestManifest(t, storePath, req.Tag).Layers {
+		want := ocispec.MediaTypeImageLayerZstd
+		if layer.Annotations[annotationPartitionPath] == rawPath {
+			want = ocispec.MediaTypeImageLayer
+		}
+		if layer.MediaType != want {
+			t.Fatalf("layer %s media type: got %q want %q", layer.Digest, layer.MediaType, want)
+		}
+	}
+
+	fetched, err := client.FetchVolume(ctx, filepath.Join(t.TempDir(), "restored"), storePath, req.Tag, FetchOptions{Concurrency: 2})
+	if err != nil {
+		t.Fatalf("FetchVolume: %v", err)
+	}
+	for _, p := range fetched.Partitions {
+		if p.Compression != wantCompression(p.Path) {
+			t.Fatalf("fetched partition %q compression: got %q want %q", p.Path, p.Compression, wantCompression(p.Path))
+		}
+	}
+
+	_, err = client.PackageVolumeWithOptions(ctx, req, PackageOptions{
+		PartitionCompression: map[string]string{"test-vol/missing": CompressionZstd},
+	})
+	if !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected ErrValidation for unknown partition, got %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package sori
+
+import (
+	"fmt"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/seoyhaein/sori/archiveutil"
+)
+
+// Layer compression algorithms accepted by PackageOptions.Compression and
+// recorded in Partition.Compression. A gzip or zstd level may be appended,
+// as in "gzip:6" or "zstd:19".
+const (
+	CompressionNone = archiveutil.CompressionNone
+	CompressionGzip = archiveutil.CompressionGzip
+	CompressionZstd = archiveutil.CompressionZstd
+)
+
+const mediaTypeDockerLayerGzip = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+
+// layerMediaType returns the OCI layer media type for a compression choice.
+func layerMediaType(c archiveutil.Compression) string {
+	switch c.AlgorithmName() {
+	case archiveutil.CompressionNone:
+		return ocispec.MediaTypeImageLayer
+	case archiveutil.CompressionZstd:
+		return ocispec.MediaTypeImageLayerZstd
+	default:
+		return ocispec.MediaTypeImageLayerGzip
+	}
+}
+
+// layerCompression maps a layer media type back onto the algorithm needed to
+// extract it.
+func layerCompression(mediaType string) (string, error) {
+	switch mediaType {
+	case ocispec.MediaTypeImageLayer:
+		return archiveutil.CompressionNone, nil
+	case ocispec.MediaTypeImageLayerGzip, mediaTypeDockerLayerGzip:
+		return archiveutil.CompressionGzip, nil
+	case ocispec.MediaTypeImageLayerZstd:
+		return archiveutil.CompressionZstd, nil
+	default:
+		return "", validationError("layerCompression", fmt.Sprintf("unsupported layer media type %q", mediaType), nil)
+	}
+}
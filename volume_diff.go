@@ -0,0 +1,418 @@
+package sori
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	godigest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+)
+
+// ChangeType classifies one entry of a diff.
+type ChangeType string
+
+const (
+	ChangeAdded    ChangeType = "added"
+	ChangeRemoved  ChangeType = "removed"
+	ChangeModified ChangeType = "modified"
+)
+
+// FileChange is a file that differs between the old and new side of a diff.
+// The Old fields are empty for added files and the New fields for removed
+// ones.
+type FileChange struct {
+	Path      string     `json:"path"`
+	Change    ChangeType `json:"change"`
+	OldSize   int64      `json:"old_size,omitempty"`
+	NewSize   int64      `json:"new_size,omitempty"`
+	OldSHA256 string     `json:"old_sha256,omitempty"`
+	NewSHA256 string     `json:"new_sha256,omitempty"`
+}
+
+// PartitionChange is a partition whose layer differs between the old and new
+// side of a diff. OldDigest is the stored layer digest; NewDigest is empty
+// when the new side has not been archived. Files lists the file changes the
+// partition's layer carries.
+type PartitionChange struct {
+	Path      string       `json:"path"`
+	Change    ChangeType   `json:"change"`
+	OldDigest string       `json:"old_digest,omitempty"`
+	NewDigest string       `json:"new_digest,omitempty"`
+	Files     []FileChange `json:"files,omitempty"`
+}
+
+// DiffResult reports how a source directory differs from a packaged volume.
+type DiffResult struct {
+	Tag            string `json:"tag"`
+	ManifestDigest string `json:"manifest_digest"`
+	// Changed is true when repackaging would produce different layers.
+	Changed bool `json:"changed"`
+	// Files lists added, removed, and modified files, sorted by path.
+	Files []FileChange `json:"files"`
+	// Partitions lists the partitions whose layers would change, sorted by
+	// path.
+	Partitions []PartitionChange `json:"partitions"`
+}
+
+// Diff compares sourceDir with the volume tagged tag in the client's local
+// store, file by file against the packaged file manifest, and reports which
+// partition layers a new PackageVolume would change. It reads sourceDir and
+// the store but writes neither. Only regular file contents and presence are
+// compared; modes, timestamps, symlinks, and empty directories are not.
+//
+// Volumes packaged without a file manifest fail with ErrNotFound.
+func (c *Client) Diff(ctx context.Context, sourceDir, tag string) (*DiffResult, error) {
+	const op = "Client.Diff"
+	info, err := os.Stat(sourceDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, notFoundError(op, fmt.Sprintf("source directory %s", sourceDir), err)
+		}
+		return nil, transportError(op, fmt.Sprintf("stat source directory %s", sourceDir), err)
+	}
+	if !info.IsDir() {
+		return nil, validationError(op, fmt.Sprintf("%s is not a directory", sourceDir), nil)
+	}
+	store, err := c.openLocalStore(op)
+	if err != nil {
+		return nil, err
+	}
+	desc, manifest, err := fetchManifest(ctx, op, store, tag)
+	if err != nil {
+		return nil, err
+	}
+	if !isVolumeManifest(manifest) {
+		return nil, validationError(op, fmt.Sprintf("%s is not a sori volume", tag), nil)
+	}
+	stored, err := fetchVolumeFileMap(ctx, op, store, desc)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return nil, notFoundError(op, fmt.Sprintf("%s was packaged without a file manifest", tag), nil)
+	}
+
+	layout := manifest.Annotations[annotationPartitionLayout]
+	sourceParts, err := sourcePartitions(sourceDir, layout)
+	if err != nil {
+		return nil, err
+	}
+	current, err := hashSourceFiles(ctx, op, sourceDir, sourceParts)
+	if err != nil {
+		return nil, err
+	}
+
+	newParts := make(map[string]string, len(sourceParts))
+	for _, p := range sourceParts {
+		newParts[p] = ""
+	}
+	files := diffFileMaps(stored, current)
+	partitions := diffPartitions(layout, layerPartitions(manifest), newParts, files)
+	return &DiffResult{
+		Tag:            tag,
+		ManifestDigest: desc.Digest.String(),
+		Changed:        len(files) > 0 || len(partitions) > 0,
+		Files:          files,
+		Partitions:     partitions,
+	}, nil
+}
+
+// sourcePartitions returns the partition paths PackageVolume would create for
+// sourceDir under layout.
+func sourcePartitions(sourceDir, layout string) ([]string, error) {
+	vi, err := GenerateVolumeIndex(sourceDir, "")
+	if err != nil {
+		return nil, err
+	}
+	rootBase := filepath.Base(filepath.Clean(sourceDir))
+	paths := make([]string, 0, len(vi.Partitions)+1)
+	for _, p := range vi.Partitions {
+		paths = append(paths, p.Path)
+	}
+	if len(paths) == 0 || (layout == PartitionLayoutExclusive && !hasPartitionPath(vi.Partitions, rootBase)) {
+		paths = append(paths, rootBase)
+	}
+	return paths, nil
+}
+
+// hashSourceFiles hashes the regular files of sourceDir that the partitions
+// would archive, keyed by their slash-separated archive path.
+func hashSourceFiles(ctx context.Context, op, sourceDir string, partitions []string) (map[string]VolumeFile, error) {
+	rootBase := filepath.Base(filepath.Clean(sourceDir))
+	files := make(map[string]VolumeFile)
+	err := filepath.WalkDir(sourceDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return transportError(op, fmt.Sprintf("access %s", path), err)
+		}
+		if err := ctx.Err(); err != nil {
+			return transportError(op, "diff canceled", err)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return transportError(op, fmt.Sprintf("get rel path for %s", path), err)
+		}
+		name := rootBase + "/" + filepath.ToSlash(rel)
+		if deepestPartition(partitions, name) == "" {
+			return nil
+		}
+		f, err := hashFile(path)
+		if err != nil {
+			return transportError(op, fmt.Sprintf("hash %s", path), err)
+		}
+		f.Path = name
+		files[name] = f
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func hashFile(path string) (VolumeFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return VolumeFile{}, err
+	}
+	defer f.Close()
+	digester := godigest.SHA256.Digester()
+	n, err := io.Copy(digester.Hash(), f)
+	if err != nil {
+		return VolumeFile{}, err
+	}
+	return VolumeFile{Size: n, SHA256: digester.Digest().String()}, nil
+}
+
+// fetchVolumeFileMap reads the file manifest attached to subject in src,
+// keyed by path. It returns nil without error when the volume has none.
+func fetchVolumeFileMap(ctx context.Context, op string, src oras.ReadOnlyTarget, subject ocispec.Descriptor) (map[string]VolumeFile, error) {
+	list, err := fetchVolumeFiles(ctx, op, src, subject)
+	if err != nil || list == nil {
+		return nil, err
+	}
+	files := make(map[string]VolumeFile, len(list.Files))
+	for _, f := range list.Files {
+		files[f.Path] = f
+	}
+	return files, nil
+}
+
+// layerPartitions maps each partition path in manifest to its layer digest.
+func layerPartitions(manifest ocispec.Manifest) map[string]string {
+	parts := make(map[string]string, len(manifest.Layers))
+	for _, layer := range manifest.Layers {
+		if path := layer.Annotations[annotationPartitionPath]; path != "" {
+			parts[path] = layer.Digest.String()
+		}
+	}
+	return parts
+}
+
+// diffFileMaps returns the file changes from old to new, sorted by path.
+func diffFileMaps(old, new map[string]VolumeFile) []FileChange {
+	changes := []FileChange{}
+	for path, o := range old {
+		n, ok := new[path]
+		switch {
+		case !ok:
+			changes = append(changes, FileChange{Path: path, Change: ChangeRemoved, OldSize: o.Size, OldSHA256: o.SHA256})
+		case n.Size != o.Size || n.SHA256 != o.SHA256:
+			changes = append(changes, FileChange{Path: path, Change: ChangeModified, OldSize: o.Size, NewSize: n.Size, OldSHA256: o.SHA256, NewSHA256: n.SHA256})
+		}
+	}
+	for path, n := range new {
+		if _, ok := old[path]; !ok {
+			changes = append(changes, FileChange{Path: path, Change: ChangeAdded, NewSize: n.Size, NewSHA256: n.SHA256})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// diffPartitions returns the partitions that are added, removed, or carry a
+// file change, sorted by path. oldParts and newParts map partition paths to
+// layer digests; an empty new digest means the layer has not been built.
+// Partitions whose digests are both known and equal are unchanged.
+func diffPartitions(layout string, oldParts, newParts map[string]string, files []FileChange) []PartitionChange {
+	all := make([]string, 0, len(oldParts)+len(newParts))
+	for p := range oldParts {
+		all = append(all, p)
+	}
+	for p := range newParts {
+		if _, ok := oldParts[p]; !ok {
+			all = append(all, p)
+		}
+	}
+	sort.Strings(all)
+
+	owned := make(map[string][]FileChange)
+	for _, f := range files {
+		owner := deepestPartition(all, f.Path)
+		for _, p := range all {
+			if !strings.HasPrefix(f.Path, p+"/") {
+				continue
+			}
+			// Nested layers also archive every descendant partition's
+			// files; exclusive layers only their own.
+			if layout == PartitionLayoutExclusive && p != owner {
+				continue
+			}
+			owned[p] = append(owned[p], f)
+		}
+	}
+
+	changes := []PartitionChange{}
+	for _, p := range all {
+		oldDigest, inOld := oldParts[p]
+		newDigest, inNew := newParts[p]
+		change := PartitionChange{Path: p, OldDigest: oldDigest, NewDigest: newDigest, Files: owned[p]}
+		switch {
+		case !inOld:
+			change.Change = ChangeAdded
+		case !inNew:
+			change.Change = ChangeRemoved
+		case newDigest != "" && newDigest == oldDigest:
+			continue
+		case newDigest != "" || len(owned[p]) > 0:
+			change.Change = ChangeModified
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// deepestPartition returns the deepest partition above file, or "" when no
+// partition contains it.
+func deepestPartition(partitions []string, file string) string {
+	owner := ""
+	for _, p := range partitions {
+		if strings.HasPrefix(file, p+"/") && len(p) > len(owner) {
+			owner = p
+		}
+	}
+	return owner
+}
+
+// DiffTarget names one side of DiffArtifacts. Ref is a tag or manifest
+// digest in the client's local store, or in the remote repository when Remote
+// is set.
+type DiffTarget struct {
+	Ref    string
+	Remote *RemoteTarget
+}
+
+// UnchangedPartition is a partition whose layer digest is the same on both
+// sides of DiffArtifacts.
+type UnchangedPartition struct {
+	Path   string `json:"path"`
+	Digest string `json:"digest"`
+}
+
+// ArtifactDiff reports how two packaged volumes differ.
+type ArtifactDiff struct {
+	From       string `json:"from"`
+	To         string `json:"to"`
+	FromDigest string `json:"from_digest"`
+	ToDigest   string `json:"to_digest"`
+	// Changed is true when any partition was added, removed, or modified.
+	Changed bool `json:"changed"`
+	// Unchanged lists the partitions with identical layer digests, sorted by
+	// path.
+	Unchanged []UnchangedPartition `json:"unchanged"`
+	// Partitions lists added, removed, and modified partitions, sorted by
+	// path.
+	Partitions []PartitionChange `json:"partitions"`
+	// Files lists the file changes between the two file manifests. It is
+	// empty when either volume was packaged without one.
+	Files []FileChange `json:"files"`
+}
+
+// DiffArtifacts compares the volume manifests from and to, matching layers by
+// partition path. Partitions whose layer digests match are reported as
+// unchanged without fetching anything beyond the manifests; when both volumes
+// carry a file manifest, the file changes are listed and attributed to the
+// partitions that hold them under the layout of to.
+func (c *Client) DiffArtifacts(ctx context.Context, from, to DiffTarget) (*ArtifactDiff, error) {
+	const op = "Client.DiffArtifacts"
+	fromDesc, fromManifest, fromFiles, err := c.loadDiffTarget(ctx, op, from)
+	if err != nil {
+		return nil, err
+	}
+	toDesc, toManifest, toFiles, err := c.loadDiffTarget(ctx, op, to)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []FileChange{}
+	if fromFiles != nil && toFiles != nil {
+		files = diffFileMaps(fromFiles, toFiles)
+	}
+	fromParts, toParts := layerPartitions(fromManifest), layerPartitions(toManifest)
+	res := &ArtifactDiff{
+		From:       from.Ref,
+		To:         to.Ref,
+		FromDigest: fromDesc.Digest.String(),
+		ToDigest:   toDesc.Digest.String(),
+		Unchanged:  []UnchangedPartition{},
+		Partitions: diffPartitions(toManifest.Annotations[annotationPartitionLayout], fromParts, toParts, files),
+		Files:      files,
+	}
+	for path, digest := range fromParts {
+		if toParts[path] == digest {
+			res.Unchanged = append(res.Unchanged, UnchangedPartition{Path: path, Digest: digest})
+		}
+	}
+	sort.Slice(res.Unchanged, func(i, j int) bool { return res.Unchanged[i].Path < res.Unchanged[j].Path })
+	res.Changed = len(res.Partitions) > 0
+	return res, nil
+}
+
+// loadDiffTarget resolves src and returns its volume manifest and file
+// manifest, which is nil when the volume has none.
+func (c *Client) loadDiffTarget(ctx context.Context, op string, src DiffTarget) (ocispec.Descriptor, ocispec.Manifest, map[string]VolumeFile, error) {
+	var target oras.ReadOnlyTarget
+	if src.Remote != nil {
+		remote := *src.Remote
+		if c.httpClient != nil {
+			remote.HTTPClient = c.httpClient
+		}
+		repo, _, err := openRemoteFetchRepository(op, remote, src.Ref)
+		if err != nil {
+			return ocispec.Descriptor{}, ocispec.Manifest{}, nil, err
+		}
+		target = repo
+	} else {
+		if strings.TrimSpace(src.Ref) == "" {
+			return ocispec.Descriptor{}, ocispec.Manifest{}, nil, validationError(op, "reference is required", nil)
+		}
+		store, err := c.openLocalStore(op)
+		if err != nil {
+			return ocispec.Descriptor{}, ocispec.Manifest{}, nil, err
+		}
+		target = store
+	}
+	desc, manifest, err := fetchManifest(ctx, op, target, src.Ref)
+	if err != nil {
+		return desc, manifest, nil, err
+	}
+	if !isVolumeManifest(manifest) {
+		return desc, manifest, nil, validationError(op, fmt.Sprintf("%s is not a sori volume", src.Ref), nil)
+	}
+	files, err := fetchVolumeFileMap(ctx, op, target, desc)
+	if err != nil {
+		return desc, manifest, nil, err
+	}
+	return desc, manifest, files, nil
+}
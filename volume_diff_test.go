@@ -0,0 +1,169 @@
+package sori
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestClientDiff(t *testing.T) {
+	for _, tc := range []struct {
+		layout string
+		want   map[string]ChangeType
+	}{
+		{PartitionLayoutNested, map[string]ChangeType{"vol/a": ChangeModified, "vol/a/deep": ChangeModified, "vol/b": ChangeModified, "vol/c": ChangeAdded}},
+		{PartitionLayoutExclusive, map[string]ChangeType{"vol/a/deep": ChangeModified, "vol/b": ChangeModified, "vol/c": ChangeAdded}},
+	} {
+		t.Run(tc.layout, func(t *testing.T) {
+			ctx := context.Background()
+			storePath := filepath.Join(t.TempDir(), "oci")
+			client := NewClient(WithLocalStorePath(storePath))
+			src := filepath.Join(t.TempDir(), "vol")
+			writeTestVolume(t, src, "payload")
+			writeTestFile(t, filepath.Join(src, "a", "deep", "d.txt"), "deep")
+			if _, err := client.PackageVolumeWithOptions(ctx, PackageRequest{SourceDir: src, DisplayName: "Diff", Tag: "diff.v1"}, PackageOptions{PartitionLayout: tc.layout}); err != nil {
+				t.Fatalf("PackageVolume: %v", err)
+			}
+
+			res, err := client.Diff(ctx, src, "diff.v1")
+			if err != nil {
+				t.Fatalf("Diff: %v", err)
+			}
+			if res.Changed || len(res.Files) != 0 || len(res.Partitions) != 0 {
+				t.Fatalf("expected no drift, got %+v", res)
+			}
+
+			writeTestFile(t, filepath.Join(src, "a", "deep", "d.txt"), "DEEP")
+			writeTestFile(t, filepath.Join(src, "b", "new.txt"), "new")
+			writeTestFile(t, filepath.Join(src, "c", "c.txt"), "c")
+			indexBefore, err := os.ReadFile(filepath.Join(storePath, "index.json"))
+			if err != nil {
+				t.Fatalf("read index.json: %v", err)
+			}
+
+			res, err = client.Diff(ctx, src, "diff.v1")
+			if err != nil {
+				t.Fatalf("Diff: %v", err)
+			}
+			gotFiles := map[string]ChangeType{}
+			for _, f := range res.Files {
+				gotFiles[f.Path] = f.Change
+			}
+			wantFiles := map[string]ChangeType{"vol/a/deep/d.txt": ChangeModified, "vol/b/new.txt": ChangeAdded, "vol/c/c.txt": ChangeAdded}
+			if !res.Changed || !reflect.DeepEqual(gotFiles, wantFiles) {
+				t.Fatalf("files: got %v want %v", gotFiles, wantFiles)
+			}
+			gotParts := map[string]ChangeType{}
+			for _, p := range res.Partitions {
+				gotParts[p.Path] = p.Change
+			}
+			if !reflect.DeepEqual(gotParts, tc.want) {
+				t.Fatalf("partitions: got %v want %v", gotParts, tc.want)
+			}
+
+			indexAfter, err := os.ReadFile(filepath.Join(storePath, "index.json"))
+			if err != nil || !bytes.Equal(indexBefore, indexAfter) {
+				t.Fatalf("Diff must not modify the store: %v", err)
+			}
+		})
+	}
+}
+
+func TestClientDiff_Errors(t *testing.T) {
+	ctx := context.Background()
+	storePath := filepath.Join(t.TempDir(), "oci")
+	client := NewClient(WithLocalStorePath(storePath))
+	src := filepath.Join(t.TempDir(), "vol")
+	writeTestVolume(t, src, "payload")
+	if _, err := client.PackageVolume(ctx, PackageRequest{SourceDir: src, DisplayName: "Diff", Tag: "diff.v1"}); err != nil {
+		t.Fatalf("PackageVolume: %v", err)
+	}
+	if _, err := client.Diff(ctx, filepath.Join(t.TempDir(), "none"), "diff.v1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for missing source, got %v", err)
+	}
+	if _, err := client.Diff(ctx, src, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for missing tag, got %v", err)
+	}
+}
+
+func TestClientDiffArtifacts(t *testing.T) {
+	ctx := context.Background()
+	storePath := filepath.Join(t.TempDir(), "oci")
+	client := NewClient(WithLocalStorePath(storePath))
+	src := filepath.Join(t.TempDir(), "vol")
+	writeTestVolume(t, src, "payload")
+	writeTestFile(t, filepath.Join(src, "c", "c.txt"), "c")
+	if _, err := client.PackageVolume(ctx, PackageRequest{SourceDir: src, DisplayName: "Diff", Tag: "diff.v1"}); err != nil {
+		t.Fatalf("PackageVolume v1: %v", err)
+	}
+	writeTestFile(t, filepath.Join(src, "a", "a.txt"), "changed")
+	if err := os.RemoveAll(filepath.Join(src, "c")); err != nil {
+		t.Fatalf("RemoveAll: %v", err)
+	}
+	writeTestFile(t, filepath.Join(src, "d", "d.txt"), "d")
+	if _, err := client.PackageVolume(ctx, PackageRequest{SourceDir: src, DisplayName: "Diff", Tag: "diff.v2"}); err != nil {
+		t.Fatalf("PackageVolume v2: %v", err)
+	}
+
+	reg := newTestRegistry(t, storePath)
+	target := reg.target("data/ref")
+	for name, to := range map[string]DiffTarget{
+		"local":  {Ref: "diff.v2"},
+		"remote": {Ref: "diff.v2", Remote: &target},
+	} {
+		t.Run(name, func(t *testing.T) {
+			res, err := client.DiffArtifacts(ctx, DiffTarget{Ref: "diff.v1"}, to)
+			if err != nil {
+				t.Fatalf("DiffArtifacts: %v", err)
+			}
+			if !res.Changed || res.FromDigest == res.ToDigest {
+				t.Fatalf("expected a change between distinct manifests: %+v", res)
+			}
+			unchanged := map[string]bool{}
+			for _, p := range res.Unchanged {
+				unchanged[p.Path] = true
+			}
+			if !unchanged["vol/b"] {
+				t.Fatalf("expected vol/b unchanged, got %+v", res.Unchanged)
+			}
+			parts := map[string]PartitionChange{}
+			for _, p := range res.Partitions {
+				parts[p.Path] = p
+			}
+			for path, want := range map[string]ChangeType{"vol/a": ChangeModified, "vol/c": ChangeRemoved, "vol/d": ChangeAdded} {
+				if parts[path].Change != want {
+					t.Fatalf("partition %s: got %q want %q", path, parts[path].Change, want)
+				}
+			}
+			if a := parts["vol/a"]; a.OldDigest == "" || a.NewDigest == "" || len(a.Files) != 1 || a.Files[0].Path != "vol/a/a.txt" {
+				t.Fatalf("unexpected vol/a change: %+v", a)
+			}
+			if _, ok := parts["vol/b"]; ok {
+				t.Fatal("vol/b must not be reported as changed")
+			}
+			gotFiles := map[string]ChangeType{}
+			for _, f := range res.Files {
+				gotFiles[f.Path] = f.Change
+			}
+			wantFiles := map[string]ChangeType{"vol/a/a.txt": ChangeModified, "vol/c/c.txt": ChangeRemoved, "vol/d/d.txt": ChangeAdded}
+			if !reflect.DeepEqual(gotFiles, wantFiles) {
+				t.Fatalf("files: got %v want %v", gotFiles, wantFiles)
+			}
+		})
+	}
+
+	same, err := client.DiffArtifacts(ctx, DiffTarget{Ref: "diff.v2"}, DiffTarget{Ref: "diff.v2"})
+	if err != nil {
+		t.Fatalf("DiffArtifacts same: %v", err)
+	}
+	if same.Changed || len(same.Partitions) != 0 || len(same.Files) != 0 {
+		t.Fatalf("expected no change, got %+v", same)
+	}
+	if _, err := client.DiffArtifacts(ctx, DiffTarget{Ref: "missing"}, DiffTarget{Ref: "diff.v2"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
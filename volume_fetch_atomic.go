@@ -0,0 +1,108 @@
+package sori
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"oras.land/oras-go/v2"
+)
+
+// fetchVolumeAtomic runs fetchVolumeFromTarget into a staging directory next
+// to destRoot, checks that every partition and volume-index.json landed, and
+// renames the staging directory into place. destRoot is never left half
+// populated: on any error the staging directory is removed.
+func fetchVolumeAtomic(ctx context.Context, op string, src oras.ReadOnlyTarget, srcName, destRoot, ref string, opts FetchOptions, progress *progressSink) (*VolumeIndex, error) {
+	destRoot = filepath.Clean(destRoot)
+	if err := ensureEmptyDir(destRoot); err != nil {
+		if errors.Is(err, ErrConflict) {
+			return nil, conflictError(op, fmt.Sprintf("atomic fetch needs a missing or empty destination, %s is not empty", destRoot), nil)
+		}
+		return nil, err
+	}
+	parent := filepath.Dir(destRoot)
+	if err := os.MkdirAll(parent, 0o755); err != nil {
+		return nil, transportError(op, fmt.Sprintf("create parent directory %s", parent), err)
+	}
+	staging, err := os.MkdirTemp(parent, "."+filepath.Base(destRoot)+".staging-*")
+	if err != nil {
+		return nil, transportError(op, "create staging directory", err)
+	}
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+		if rmErr := os.RemoveAll(staging); rmErr != nil {
+			Log.Warnf("failed to remove staging directory %s: %v", staging, rmErr)
+		}
+	}()
+
+	opts.Atomic = false
+	vi, err := fetchVolumeFromTarget(ctx, op, src, srcName, staging, ref, opts, progress)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyStagedVolume(ctx, op, staging, vi); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, transportError(op, "fetch canceled", err)
+	}
+
+	// MkdirTemp creates the staging directory as 0700. Give it the mode of
+	// the destination it replaces, or the usual 0755 for a new one.
+	mode := os.FileMode(0o755)
+	if info, err := os.Stat(destRoot); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.Chmod(staging, mode); err != nil {
+		return nil, transportError(op, fmt.Sprintf("set mode of staging directory %s", staging), err)
+	}
+
+	// os.Rename cannot replace a directory everywhere, so drop the empty
+	// destination first. A destination that reappears in between makes the
+	// rename fail, which is reported as a conflict.
+	if err := os.Remove(destRoot); err != nil && !os.IsNotExist(err) {
+		return nil, conflictError(op, fmt.Sprintf("remove empty destination %s", destRoot), err)
+	}
+	if err := os.Rename(staging, destRoot); err != nil {
+		return nil, conflictError(op, fmt.Sprintf("rename staging directory into %s", destRoot), err)
+	}
+	committed = true
+	return vi, nil
+}
+
+// verifyStagedVolume checks that volume-index.json and every partition
+// directory listed in vi exist under staging. When the fetch wrote
+// volume-files.json, every staged file is also checked against it the way
+// VerifyVolume does, so a truncated or corrupted layer is never swapped in.
+func verifyStagedVolume(ctx context.Context, op, staging string, vi *VolumeIndex) error {
+	if _, err := os.Stat(filepath.Join(staging, VolumeIndexJson)); err != nil {
+		return integrityError(op, "staged volume has no "+VolumeIndexJson, err)
+	}
+	for _, p := range vi.Partitions {
+		info, err := os.Stat(filepath.Join(staging, filepath.FromSlash(p.Path)))
+		if err != nil {
+			return integrityError(op, fmt.Sprintf("staged partition %s is missing", p.Path), err)
+		}
+		if !info.IsDir() {
+			return integrityError(op, fmt.Sprintf("staged partition %s is not a directory", p.Path), nil)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(staging, VolumeFilesJson)); os.IsNotExist(err) {
+		return nil
+	}
+	report, err := verifyVolumeDir(ctx, op, staging)
+	if err != nil {
+		return err
+	}
+	if !report.OK() {
+		return integrityError(op, fmt.Sprintf("staged volume does not match %s: missing %v, modified %v, extra %v",
+			VolumeFilesJson, report.Missing, report.Modified, report.Extra), nil)
+	}
+	return nil
+}
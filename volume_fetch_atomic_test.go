@@ -0,0 +1,124 @@
+package sori
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/seoyhaein/sori/archiveutil"
+)
+
+func TestClientFetchVolume_Atomic(t *testing.T) {
+	ctx := context.Background()
+	storePath := filepath.Join(t.TempDir(), "oci")
+	client := NewClient(WithLocalStorePath(storePath))
+	src := filepath.Join(t.TempDir(), "vol")
+	writeTestVolume(t, src, "payload")
+	pkg, err := client.PackageVolume(ctx, PackageRequest{SourceDir: src, DisplayName: "Atomic", Tag: "atomic.v1"})
+	if err != nil {
+		t.Fatalf("PackageVolume: %v", err)
+	}
+
+	parent := t.TempDir()
+	dest := filepath.Join(parent, "ref")
+	vi, err := client.FetchVolume(ctx, dest, storePath, "atomic.v1", FetchOptions{Atomic: true})
+	if err != nil {
+		t.Fatalf("FetchVolume: %v", err)
+	}
+	if len(vi.Partitions) != len(pkg.Partitions) {
+		t.Fatalf("partition count: got %d want %d", len(vi.Partitions), len(pkg.Partitions))
+	}
+	for _, rel := range []string{VolumeIndexJson, "vol/a/a.txt", "vol/b/b.txt"} {
+		if _, err := os.Stat(filepath.Join(dest, filepath.FromSlash(rel))); err != nil {
+			t.Fatalf("expected %s in destination: %v", rel, err)
+		}
+	}
+	assertOnlyEntry(t, parent, "ref")
+	assertDirMode(t, dest, 0o755)
+
+	existing := filepath.Join(t.TempDir(), "ref")
+	if err := os.Mkdir(existing, 0o750); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	if err := os.Chmod(existing, 0o750); err != nil {
+		t.Fatalf("Chmod: %v", err)
+	}
+	if _, err := client.FetchVolume(ctx, existing, storePath, "atomic.v1", FetchOptions{Atomic: true}); err != nil {
+		t.Fatalf("FetchVolume into empty destination: %v", err)
+	}
+	assertDirMode(t, existing, 0o750)
+
+	if _, err := client.FetchVolume(ctx, dest, storePath, "atomic.v1", FetchOptions{Atomic: true}); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected ErrConflict for a populated destination, got %v", err)
+	}
+
+	// Swap one layer for a valid archive with different content: extraction
+	// succeeds, but the staged files no longer match the file manifest.
+	tampered := t.TempDir()
+	writeTestFile(t, filepath.Join(tampered, "a.txt"), "tampered")
+	archive, err := archiveutil.TarGzDir(tampered, "vol/a")
+	if err != nil {
+		t.Fatalf("TarGzDir: %v", err)
+	}
+	for _, p := range pkg.Partitions {
+		if p.Path != "vol/a" {
+			continue
+		}
+		blob := filepath.Join(storePath, ocispec.ImageBlobsDir, "sha256", p.ManifestRef[len("sha256:"):])
+		if err := os.WriteFile(blob, archive, 0o644); err != nil {
+			t.Fatalf("tamper layer: %v", err)
+		}
+	}
+	tamperedParent := t.TempDir()
+	if _, err := client.FetchVolume(ctx, filepath.Join(tamperedParent, "ref"), storePath, "atomic.v1", FetchOptions{Atomic: true}); !errors.Is(err, ErrIntegrity) {
+		t.Fatalf("expected ErrIntegrity for a staged file mismatch, got %v", err)
+	}
+	assertOnlyEntry(t, tamperedParent, "")
+
+	// Corrupt one layer so extraction fails part way through.
+	layer := pkg.Partitions[len(pkg.Partitions)-1].ManifestRef
+	blob := filepath.Join(storePath, ocispec.ImageBlobsDir, "sha256", layer[len("sha256:"):])
+	if err := os.WriteFile(blob, []byte("not a gzip stream"), 0o644); err != nil {
+		t.Fatalf("corrupt layer: %v", err)
+	}
+	failParent := t.TempDir()
+	failDest := filepath.Join(failParent, "ref")
+	if _, err := client.FetchVolume(ctx, failDest, storePath, "atomic.v1", FetchOptions{Atomic: true}); err == nil {
+		t.Fatal("expected fetch of a corrupt layer to fail")
+	}
+	if _, err := os.Stat(failDest); !os.IsNotExist(err) {
+		t.Fatalf("failed atomic fetch must not create the destination, got %v", err)
+	}
+	assertOnlyEntry(t, failParent, "")
+}
+
+// assertOnlyEntry fails unless dir contains exactly name, or nothing when
+// name is empty.
+func assertOnlyEntry(t *testing.T, dir, name string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if (name == "" && len(names) != 0) || (name != "" && (len(names) != 1 || names[0] != name)) {
+		t.Fatalf("unexpected entries in %s: %v", dir, names)
+	}
+}
+
+func assertDirMode(t *testing.T, dir string, want os.FileMode) {
+	t.Helper()
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatalf("Stat %s: %v", dir, err)
+	}
+	if got := info.Mode().Perm(); got != want {
+		t.Fatalf("mode of %s: got %o want %o", dir, got, want)
+	}
+}
@@ -0,0 +1,237 @@
+package sori
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/oci"
+	"oras.land/oras-go/v2/errdef"
+)
+
+const (
+	// CacheHit marks a partition whose layer was served from the local OCI
+	// store during a pull-through fetch.
+	CacheHit = "hit"
+	// CacheMiss marks a partition whose layer was downloaded from the remote
+	// registry and written into the local OCI store during a pull-through fetch.
+	CacheMiss = "miss"
+)
+
+// layerCacheFetcher is implemented by fetch sources that can report whether a
+// layer came from the local cache.
+type layerCacheFetcher interface {
+	fetchLayer(ctx context.Context, desc ocispec.Descriptor) (io.ReadCloser, string, error)
+}
+
+func fetchLayer(ctx context.Context, src oras.ReadOnlyTarget, desc ocispec.Descriptor) (io.ReadCloser, string, error) {
+	if cf, ok := src.(layerCacheFetcher); ok {
+		return cf.fetchLayer(ctx, desc)
+	}
+	rc, err := src.Fetch(ctx, desc)
+	return rc, "", err
+}
+
+// pullThroughTarget reads from a remote registry through a local OCI store.
+// Blobs missing from the store are downloaded once and written into it, and
+// references already cached are resolved without contacting the remote.
+//
+// A remote tag is only tagged in the store once the manifest, its config and
+// every layer are cached, so a cached tag can always be inspected or pushed.
+type pullThroughTarget struct {
+	remote     oras.ReadOnlyTarget
+	cache      *oci.Store
+	remoteRepo string
+
+	mu      sync.Mutex
+	pending []*pendingCacheTag
+}
+
+// pendingCacheTag is a cached manifest waiting for its missing layers before
+// it is tagged.
+type pendingCacheTag struct {
+	desc    ocispec.Descriptor
+	ref     string
+	missing map[digest.Digest]bool
+}
+
+func newPullThroughTarget(remote oras.ReadOnlyTarget, cachePath, remoteRepo string) (*pullThroughTarget, error) {
+	store, err := oci.New(cachePath)
+	if err != nil {
+		return nil, transportError("newPullThroughTarget", "open local OCI cache", err)
+	}
+	return &pullThroughTarget{remote: remote, cache: store, remoteRepo: remoteRepo}, nil
+}
+
+// cacheRef returns the local tag used for a remote reference. Tags are
+// namespaced by the remote repository so they never collide with locally
+// packaged tags; digests are stored as-is.
+func (t *pullThroughTarget) cacheRef(ref string) string {
+	if _, err := digest.Parse(ref); err == nil {
+		return ref
+	}
+	return t.remoteRepo + ":" + ref
+}
+
+func (t *pullThroughTarget) Resolve(ctx context.Context, ref string) (ocispec.Descriptor, error) {
+	localRef := t.cacheRef(ref)
+	if desc, err := t.cache.Resolve(ctx, localRef); err == nil {
+		Log.Infof("pull-through cache hit for %s", localRef)
+		return desc, nil
+	} else if !errors.Is(err, errdef.ErrNotFound) {
+		return ocispec.Descriptor{}, err
+	}
+
+	desc, err := t.remote.Resolve(ctx, ref)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	if _, err := pushBlobIfMissing(ctx, "pullThroughTarget.Resolve", t.cache, desc, func() (io.ReadCloser, error) {
+		return t.remote.Fetch(ctx, desc)
+	}); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	if localRef != desc.Digest.String() {
+		if err := t.tagWhenCached(ctx, desc, localRef); err != nil {
+			return ocispec.Descriptor{}, err
+		}
+	}
+	return desc, nil
+}
+
+// tagWhenCached caches the config of the manifest desc and tags it as ref,
+// or defers the tag until fetchLayer has cached the layers still missing.
+// Manifests other than image manifests are left untagged.
+func (t *pullThroughTarget) tagWhenCached(ctx context.Context, desc ocispec.Descriptor, ref string) error {
+	const op = "pullThroughTarget.Resolve"
+	if desc.MediaType != ocispec.MediaTypeImageManifest {
+		return nil
+	}
+	data, err := content.FetchAll(ctx, t.cache, desc)
+	if err != nil {
+		return transportError(op, "read cached manifest", err)
+	}
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return integrityError(op, "decode cached manifest", err)
+	}
+	if _, err := pushBlobIfMissing(ctx, op, t.cache, manifest.Config, func() (io.ReadCloser, error) {
+		return t.remote.Fetch(ctx, manifest.Config)
+	}); err != nil {
+		return err
+	}
+	missing := make(map[digest.Digest]bool)
+	for _, layer := range manifest.Layers {
+		exists, err := t.cache.Exists(ctx, layer)
+		if err != nil {
+			return transportError(op, fmt.Sprintf("check exists %s", layer.Digest), err)
+		}
+		if !exists {
+			missing[layer.Digest] = true
+		}
+	}
+	if len(missing) > 0 {
+		t.mu.Lock()
+		t.pending = append(t.pending, &pendingCacheTag{desc: desc, ref: ref, missing: missing})
+		t.mu.Unlock()
+		return nil
+	}
+	return t.tag(ctx, op, desc, ref)
+}
+
+// layerCached tags every pending manifest whose last missing layer is dgst.
+func (t *pullThroughTarget) layerCached(ctx context.Context, dgst digest.Digest) error {
+	t.mu.Lock()
+	var ready []*pendingCacheTag
+	pending := t.pending[:0]
+	for _, p := range t.pending {
+		delete(p.missing, dgst)
+		if len(p.missing) == 0 {
+			ready = append(ready, p)
+			continue
+		}
+		pending = append(pending, p)
+	}
+	t.pending = pending
+	t.mu.Unlock()
+
+	for _, p := range ready {
+		if err := t.tag(ctx, "pullThroughTarget.Fetch", p.desc, p.ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *pullThroughTarget) tag(ctx context.Context, op string, desc ocispec.Descriptor, ref string) error {
+	if err := t.cache.Tag(ctx, desc, ref); err != nil {
+		return transportError(op, fmt.Sprintf("tag cached manifest %q", ref), err)
+	}
+	return nil
+}
+
+func (t *pullThroughTarget) Fetch(ctx context.Context, desc ocispec.Descriptor) (io.ReadCloser, error) {
+	rc, _, err := t.fetchLayer(ctx, desc)
+	return rc, err
+}
+
+func (t *pullThroughTarget) Exists(ctx context.Context, desc ocispec.Descriptor) (bool, error) {
+	exists, err := t.cache.Exists(ctx, desc)
+	if err != nil || exists {
+		return exists, err
+	}
+	return t.remote.Exists(ctx, desc)
+}
+
+func (t *pullThroughTarget) fetchLayer(ctx context.Context, desc ocispec.Descriptor) (io.ReadCloser, string, error) {
+	pushed, err := pushBlobIfMissing(ctx, "pullThroughTarget.Fetch", t.cache, desc, func() (io.ReadCloser, error) {
+		return t.remote.Fetch(ctx, desc)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if err := t.layerCached(ctx, desc.Digest); err != nil {
+		return nil, "", err
+	}
+	rc, err := t.cache.Fetch(ctx, desc)
+	if err != nil {
+		return nil, "", err
+	}
+	if pushed {
+		return rc, CacheMiss, nil
+	}
+	return rc, CacheHit, nil
+}
+
+// FetchRemoteVolumeCached fetches a packaged dataset from a remote registry
+// through the local OCI store at cachePath.
+//
+// Blobs are written into the local store as they are downloaded, so a later
+// fetch of the same tag or digest is served locally without network traffic.
+// Each returned Partition reports CacheHit or CacheMiss in CacheStatus.
+func FetchRemoteVolumeCached(ctx context.Context, destRoot string, target RemoteTarget, ref, cachePath string, concurrency int) (*VolumeIndex, error) {
+	if strings.TrimSpace(cachePath) == "" {
+		return nil, validationError("FetchRemoteVolumeCached", "local cache path is required", nil)
+	}
+	repo, remoteRepo, err := openRemoteFetchRepository("FetchRemoteVolumeCached", target, ref)
+	if err != nil {
+		return nil, err
+	}
+	src, err := newPullThroughTarget(repo, cachePath, remoteRepo)
+	if err != nil {
+		return nil, err
+	}
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+	return fetchVolumeFromTarget(ctx, "FetchRemoteVolumeCached", src, remoteRepo, destRoot, ref, FetchOptions{Concurrency: concurrency}, nil)
+}
@@ -0,0 +1,149 @@
+package sori
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CacheReused marks a partition an incremental fetch kept from the previous
+// extraction in destRoot because its layer digest did not change.
+const CacheReused = "reused"
+
+// prepareIncrementalFetch compares the volume-index.json already in destRoot
+// with wanted, the partition paths and layer digests about to be fetched, and
+// returns the partitions that can be kept as they are. It then clears every
+// partition that will be extracted again and every partition that is no
+// longer wanted, sparing the subtrees of kept partitions.
+//
+// Before anything is deleted volume-index.json is rewritten to list only the
+// kept partitions, so a fetch that fails halfway can be retried
+// incrementally. Without a previous volume-index.json nothing is kept or
+// removed.
+func prepareIncrementalFetch(op, destRoot, layout string, wanted map[string]string) (map[string]struct{}, error) {
+	data, err := os.ReadFile(filepath.Join(destRoot, VolumeIndexJson))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, transportError(op, "read existing "+VolumeIndexJson, err)
+	}
+	var prev VolumeIndex
+	if err := json.Unmarshal(data, &prev); err != nil {
+		return nil, integrityError(op, "decode existing "+VolumeIndexJson, err)
+	}
+	for _, p := range prev.Partitions {
+		if !filepath.IsLocal(filepath.FromSlash(p.Path)) {
+			return nil, integrityError(op, fmt.Sprintf("existing %s lists unsafe partition path %q", VolumeIndexJson, p.Path), nil)
+		}
+	}
+	for p := range wanted {
+		if !filepath.IsLocal(filepath.FromSlash(p)) {
+			return nil, integrityError(op, fmt.Sprintf("unsafe partition path %q", p), nil)
+		}
+	}
+
+	// A layout change moves files between layers, so nothing is reused.
+	reused := make(map[string]struct{})
+	if prev.Layout == layout {
+		for _, p := range prev.Partitions {
+			if digest, ok := wanted[p.Path]; ok && digest == p.ManifestRef {
+				reused[p.Path] = struct{}{}
+			}
+		}
+	}
+
+	interim := &VolumeIndex{Layout: layout, Partitions: []Partition{}}
+	for _, p := range prev.Partitions {
+		if _, ok := reused[p.Path]; ok {
+			interim.Partitions = append(interim.Partitions, p)
+		}
+	}
+	if err := writeVolumeIndex(destRoot, interim); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(filepath.Join(destRoot, VolumeFilesJson)); err != nil && !os.IsNotExist(err) {
+		return nil, transportError(op, "remove existing "+VolumeFilesJson, err)
+	}
+
+	for p := range wanted {
+		if _, ok := reused[p]; ok {
+			continue
+		}
+		if err := clearPartition(destRoot, p, reused); err != nil {
+			return nil, transportError(op, fmt.Sprintf("clear changed partition %s", p), err)
+		}
+	}
+	for _, p := range prev.Partitions {
+		if _, ok := wanted[p.Path]; ok {
+			continue
+		}
+		// A nested layer also carries its descendants' files, so a dropped
+		// partition below a wanted one is left to that partition.
+		if layout != PartitionLayoutExclusive && hasAncestorPartition(wanted, p.Path) {
+			continue
+		}
+		if err := clearPartition(destRoot, p.Path, reused); err != nil {
+			return nil, transportError(op, fmt.Sprintf("remove partition %s", p.Path), err)
+		}
+		// The directory stays when kept partitions live below it.
+		_ = os.Remove(filepath.Join(destRoot, filepath.FromSlash(p.Path)))
+	}
+	return reused, nil
+}
+
+// clearPartition removes everything under destRoot/part except the subtrees
+// of the kept partitions.
+func clearPartition(destRoot, part string, kept map[string]struct{}) error {
+	var keep []string
+	for k := range kept {
+		if strings.HasPrefix(k, part+"/") {
+			keep = append(keep, filepath.Join(destRoot, filepath.FromSlash(k)))
+		}
+	}
+	return clearDirExcept(filepath.Join(destRoot, filepath.FromSlash(part)), keep)
+}
+
+func clearDirExcept(dir string, keep []string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, e := range entries {
+		child := filepath.Join(dir, e.Name())
+		kept, below := false, false
+		for _, k := range keep {
+			if k == child {
+				kept = true
+			} else if strings.HasPrefix(k, child+string(filepath.Separator)) {
+				below = true
+			}
+		}
+		switch {
+		case kept:
+		case below && e.IsDir():
+			if err := clearDirExcept(child, keep); err != nil {
+				return err
+			}
+		default:
+			if err := os.RemoveAll(child); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func hasAncestorPartition(partitions map[string]string, path string) bool {
+	for p := range partitions {
+		if strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package sori
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClientFetchVolume_Incremental(t *testing.T) {
+	for _, layout := range []string{PartitionLayoutNested, PartitionLayoutExclusive} {
+		t.Run(layout, func(t *testing.T) {
+			ctx := context.Background()
+			storePath := filepath.Join(t.TempDir(), "oci")
+			var skipped []string
+			client := NewClient(
+				WithLocalStorePath(storePath),
+				WithProgressReporter(ProgressFunc(func(e ProgressEvent) {
+					if e.Type == ProgressLayerSkipped {
+						skipped = append(skipped, e.Partition)
+					}
+				})),
+			)
+			src := filepath.Join(t.TempDir(), "vol")
+			writeTestVolume(t, src, "payload")
+			writeTestFile(t, filepath.Join(src, "a", "deep", "d.txt"), "deep")
+			writeTestFile(t, filepath.Join(src, "c", "c.txt"), "c")
+			pkgOpts := PackageOptions{PartitionLayout: layout}
+			if _, err := client.PackageVolumeWithOptions(ctx, PackageRequest{SourceDir: src, DisplayName: "Inc", Tag: "inc.v1"}, pkgOpts); err != nil {
+				t.Fatalf("PackageVolume v1: %v", err)
+			}
+
+			dest := filepath.Join(t.TempDir(), "restored")
+			opts := FetchOptions{Incremental: true}
+			if _, err := client.FetchVolume(ctx, dest, storePath, "inc.v1", opts); err != nil {
+				t.Fatalf("FetchVolume v1: %v", err)
+			}
+			if len(skipped) != 0 {
+				t.Fatalf("first fetch has nothing to reuse, skipped %v", skipped)
+			}
+
+			writeTestFile(t, filepath.Join(src, "b", "b.txt"), "updated")
+			if err := os.RemoveAll(filepath.Join(src, "c")); err != nil {
+				t.Fatalf("RemoveAll: %v", err)
+			}
+			writeTestFile(t, filepath.Join(src, "e", "e.txt"), "e")
+			if _, err := client.PackageVolumeWithOptions(ctx, PackageRequest{SourceDir: src, DisplayName: "Inc", Tag: "inc.v2"}, pkgOpts); err != nil {
+				t.Fatalf("PackageVolume v2: %v", err)
+			}
+			writeTestFile(t, filepath.Join(dest, "vol", "b", "stale.txt"), "stale")
+
+			vi, err := client.FetchVolume(ctx, dest, storePath, "inc.v2", opts)
+			if err != nil {
+				t.Fatalf("FetchVolume v2: %v", err)
+			}
+			status := map[string]string{}
+			for _, p := range vi.Partitions {
+				status[p.Path] = p.CacheStatus
+			}
+			for _, path := range []string{"vol/a", "vol/a/deep"} {
+				if status[path] != CacheReused {
+					t.Fatalf("expected %s reused, got statuses %v", path, status)
+				}
+			}
+			if s, ok := status["vol/b"]; !ok || s == CacheReused {
+				t.Fatalf("expected vol/b fetched again, got statuses %v", status)
+			}
+			if _, ok := status["vol/c"]; ok {
+				t.Fatalf("vol/c must not be listed, got %v", status)
+			}
+			if len(skipped) < 2 {
+				t.Fatalf("expected skipped layers for reused partitions, got %v", skipped)
+			}
+
+			for _, gone := range []string{"vol/c", "vol/b/stale.txt"} {
+				if _, err := os.Stat(filepath.Join(dest, filepath.FromSlash(gone))); !os.IsNotExist(err) {
+					t.Fatalf("expected %s removed, stat err %v", gone, err)
+				}
+			}
+			data, err := os.ReadFile(filepath.Join(dest, "vol", "b", "b.txt"))
+			if err != nil || string(data) != "updated" {
+				t.Fatalf("vol/b/b.txt: %q, %v", data, err)
+			}
+			report, err := client.VerifyVolume(ctx, dest)
+			if err != nil {
+				t.Fatalf("VerifyVolume: %v", err)
+			}
+			if !report.OK() {
+				t.Fatalf("incremental fetch does not match the file manifest: %+v", report)
+			}
+		})
+	}
+}
+
+func TestClientFetchVolume_IncrementalRejectsAtomic(t *testing.T) {
+	_, err := NewClient().FetchVolume(context.Background(), t.TempDir(), t.TempDir(), "v1", FetchOptions{Incremental: true, Atomic: true})
+	if !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected ErrValidation, got %v", err)
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -20,6 +22,7 @@ import (
 	"oras.land/oras-go/v2"
 	"oras.land/oras-go/v2/content"
 	"oras.land/oras-go/v2/content/oci"
+	"oras.land/oras-go/v2/errdef"
 	"oras.land/oras-go/v2/registry/remote"
 )
 
@@ -30,126 +33,337 @@ func (vi *VolumeIndex) PublishVolume(ctx context.Context, volPath, volName strin
 	return NewClient().PublishVolume(ctx, vi, volPath, volName, configBlob)
 }
 
-func (vi *VolumeIndex) publishVolumeToStore(ctx context.Context, storePath, volPath, volName string, configBlob []byte) (*VolumeIndex, error) {
+// publishOptions carries packaging choices from the core options into
+// publishVolumeToStore.
+type publishOptions struct {
+	layout      string
+	annotations map[string]string
+	// compression applies to every layer unless partitionCompression has an
+	// entry for the partition path.
+	compression          archiveutil.Compression
+	partitionCompression map[string]archiveutil.Compression
+	progress             *progressSink
+	// fullVerification archives every partition even when the packaging
+	// cache says its directory is unchanged.
+	fullVerification bool
+}
+
+func (vi *VolumeIndex) publishVolumeToStore(ctx context.Context, storePath, volPath, volName string, configBlob []byte, opts publishOptions) (*VolumeIndex, error) {
 	store, err := oci.New(storePath)
 	if err != nil {
 		return nil, transportError("VolumeIndex.publishVolumeToStore", "init OCI store", err)
 	}
 
 	anyPushed := false
-	pushIfNeeded := func(desc ocispec.Descriptor, r io.Reader) (*bool, error) {
-		exists, err := store.Exists(ctx, desc)
-		if err != nil {
-			return nil, transportError("VolumeIndex.publishVolumeToStore", fmt.Sprintf("check exists %s", desc.Digest), err)
-		}
-		if exists {
-			Log.Infof("blob %s already exists, skipping", desc.Digest)
-			skipped := false
-			return &skipped, nil
-		}
-		if err := store.Push(ctx, desc, r); err != nil {
-			return nil, transportError("VolumeIndex.publishVolumeToStore", fmt.Sprintf("push blob %s", desc.Digest), err)
-		}
-		pushed := true
-		return &pushed, nil
+	pushIfNeeded := func(desc ocispec.Descriptor, r io.Reader) (bool, error) {
+		return pushBlobIfMissing(ctx, "VolumeIndex.publishVolumeToStore", store, desc, func() (io.ReadCloser, error) {
+			return io.NopCloser(r), nil
+		})
 	}
 
 	configDesc := ocispec.Descriptor{
-		MediaType: ocispec.MediaTypeImageConfig,
+		MediaType: MediaTypeVolumeConfig,
 		Digest:    digest.FromBytes(configBlob),
 		Size:      int64(len(configBlob)),
 	}
-	pushedPtr, err := pushIfNeeded(configDesc, bytes.NewReader(configBlob))
+	pushed, err := pushIfNeeded(configDesc, bytes.NewReader(configBlob))
 	if err != nil {
 		return nil, err
 	}
-	if pushedPtr != nil && *pushedPtr {
+	if pushed {
 		anyPushed = true
 	}
 
 	rootBase := filepath.Base(volPath)
 	layers := make([]ocispec.Descriptor, 0, len(vi.Partitions))
+	tempDir := filepath.Join(storePath, storeTempDir)
+
+	exclusive := opts.layout == PartitionLayoutExclusive
+	if exclusive {
+		vi.Layout = PartitionLayoutExclusive
+		if !hasPartitionPath(vi.Partitions, rootBase) {
+			root := Partition{Name: rootBase, Path: rootBase, CreatedAt: vi.CreatedAt}
+			vi.Partitions = append([]Partition{root}, vi.Partitions...)
+		}
+	}
 
-	if len(vi.Partitions) == 0 {
-		layerData, err := archiveutil.TarGzDir(volPath, rootBase)
+	compressionFor := func(partPath string) archiveutil.Compression {
+		if c, ok := opts.partitionCompression[partPath]; ok {
+			return c
+		}
+		return opts.compression
+	}
+	partPaths := make(map[string]struct{}, len(vi.Partitions)+1)
+	partPaths[rootBase] = struct{}{}
+	for _, p := range vi.Partitions {
+		partPaths[p.Path] = struct{}{}
+	}
+	for partPath := range opts.partitionCompression {
+		if _, ok := partPaths[partPath]; !ok {
+			return nil, validationError("VolumeIndex.publishVolumeToStore", fmt.Sprintf("compression set for unknown partition %q", partPath), nil)
+		}
+	}
+
+	// Nested partitions archive the same file more than once, so the file
+	// manifest is keyed by path.
+	files := make(map[string]VolumeFile)
+	recordFile := func(name string, size int64, sum digest.Digest) {
+		files[name] = VolumeFile{Path: name, Size: size, SHA256: sum.String()}
+	}
+
+	cache := loadPackageCache(storePath)
+	pushLayer := func(fsPath, partPath string, tarOpts archiveutil.TarOptions) (ocispec.Descriptor, error) {
+		tarOpts.Compression = compressionFor(partPath)
+		// The fingerprint is taken before archiving so that a change made
+		// while the layer is written invalidates the entry next time.
+		fingerprint, err := layerFingerprint(fsPath, partPath, tarOpts)
+		if err != nil {
+			return ocispec.Descriptor{}, transportError("VolumeIndex.publishVolumeToStore", fmt.Sprintf("fingerprint %q", fsPath), err)
+		}
+		if entry, ok := cache.lookup(fsPath, partPath, fingerprint); ok && !opts.fullVerification {
+			exists, err := store.Exists(ctx, entry.Layer)
+			if err != nil {
+				return ocispec.Descriptor{}, transportError("VolumeIndex.publishVolumeToStore", fmt.Sprintf("check exists %s", entry.Layer.Digest), err)
+			}
+			if exists {
+				Log.Infof("partition %s unchanged since layer %s was archived, skipping", partPath, entry.Layer.Digest)
+				for _, f := range entry.Files {
+					files[f.Path] = f
+				}
+				desc := entry.Layer
+				desc.Annotations = map[string]string{annotationPartitionPath: partPath}
+				opts.progress.layerSkipped(partPath, desc)
+				return desc, nil
+			}
+		}
+
+		var layerFiles []VolumeFile
+		tarOpts.OnFile = func(name string, size int64, sum digest.Digest) {
+			recordFile(name, size, sum)
+			layerFiles = append(layerFiles, files[name])
+		}
+		tarOpts.Progress = opts.progress.counter(partPath, ocispec.Descriptor{})
+		opts.progress.partitionStarted(partPath, ocispec.Descriptor{})
+		archive, err := archiveutil.TarGzDirToTempFile(fsPath, partPath, tempDir, tarOpts)
 		if err != nil {
-			return nil, transportError("VolumeIndex.publishVolumeToStore", fmt.Sprintf("tar.gz fallback %q", volPath), err)
+			return ocispec.Descriptor{}, transportError("VolumeIndex.publishVolumeToStore", fmt.Sprintf("archive %q", fsPath), err)
 		}
+		defer func() {
+			if rErr := archive.Remove(); rErr != nil {
+				Log.Warnf("failed to remove temp layer %s: %v", archive.Path, rErr)
+			}
+		}()
+
 		desc := ocispec.Descriptor{
-			MediaType: ocispec.MediaTypeImageLayerGzip,
-			Digest:    digest.FromBytes(layerData),
-			Size:      int64(len(layerData)),
+			MediaType: layerMediaType(tarOpts.Compression),
+			Digest:    archive.Digest,
+			Size:      archive.Size,
 			Annotations: map[string]string{
-				"org.example.partitionPath": rootBase,
+				annotationPartitionPath: partPath,
 			},
 		}
-		pushedPtr, err := pushIfNeeded(desc, bytes.NewReader(layerData))
+		pushed, err := pushBlobIfMissing(ctx, "VolumeIndex.publishVolumeToStore", store, desc, func() (io.ReadCloser, error) {
+			return os.Open(archive.Path)
+		})
 		if err != nil {
-			return nil, transportError("VolumeIndex.publishVolumeToStore", "push fallback layer", err)
+			return ocispec.Descriptor{}, err
 		}
-		if pushedPtr != nil && *pushedPtr {
+		if pushed {
 			anyPushed = true
+			opts.progress.layerDone(partPath, desc, desc.Size)
+		} else {
+			opts.progress.layerSkipped(partPath, desc)
+		}
+		cache.put(fsPath, partPath, fingerprint, ocispec.Descriptor{MediaType: desc.MediaType, Digest: desc.Digest, Size: desc.Size}, layerFiles)
+		return desc, nil
+	}
+
+	if len(vi.Partitions) == 0 {
+		desc, err := pushLayer(volPath, rootBase, archiveutil.TarOptions{})
+		if err != nil {
+			return nil, transportError("VolumeIndex.publishVolumeToStore", "push fallback layer", err)
 		}
 		layers = append(layers, desc)
 	} else {
 		for i := range vi.Partitions {
 			part := &vi.Partitions[i]
-			fsPath := filepath.Join(volPath, strings.TrimPrefix(part.Path, rootBase+"/"))
-			layerData, err := archiveutil.TarGzDir(fsPath, part.Path)
-			if err != nil {
-				return nil, transportError("VolumeIndex.publishVolumeToStore", fmt.Sprintf("tar.gz %q", fsPath), err)
+			fsPath := partitionFSPath(volPath, rootBase, part.Path)
+			var tarOpts archiveutil.TarOptions
+			if exclusive {
+				tarOpts.ExcludeDirs = childPartitionDirs(vi.Partitions, part.Path)
 			}
-			desc := ocispec.Descriptor{
-				MediaType: ocispec.MediaTypeImageLayerGzip,
-				Digest:    digest.FromBytes(layerData),
-				Size:      int64(len(layerData)),
-				Annotations: map[string]string{
-					"org.example.partitionPath": part.Path,
-				},
-			}
-			pushedPtr, err := pushIfNeeded(desc, bytes.NewReader(layerData))
+			desc, err := pushLayer(fsPath, part.Path, tarOpts)
 			if err != nil {
 				return nil, transportError("VolumeIndex.publishVolumeToStore", fmt.Sprintf("push layer %s", part.Name), err)
 			}
-			if pushedPtr != nil && *pushedPtr {
-				anyPushed = true
-			}
 			part.ManifestRef = desc.Digest.String()
+			part.Compression = compressionFor(part.Path).AlgorithmName()
 			layers = append(layers, desc)
 		}
 	}
 
+	if err := cache.save(); err != nil {
+		Log.Warnf("failed to save packaging cache %s: %v", cache.path, err)
+	}
+
+	manifestAnnotations := map[string]string{
+		ocispec.AnnotationCreated: time.Now().UTC().Format(time.RFC3339),
+	}
+	for k, v := range opts.annotations {
+		manifestAnnotations[k] = v
+	}
+	if exclusive {
+		manifestAnnotations[annotationPartitionLayout] = PartitionLayoutExclusive
+	}
+
 	if !anyPushed {
 		existingDesc, err := store.Resolve(ctx, volName)
 		if err == nil {
-			Log.Infof("No changes detected (config+layers), skipping manifest update for %q", volName)
-			vi.VolumeRef = existingDesc.Digest.String()
-			return vi, nil
+			unchanged, err := manifestUnchanged(ctx, store, existingDesc, configDesc, layers, manifestAnnotations)
+			if err != nil {
+				return nil, err
+			}
+			if unchanged {
+				Log.Infof("No changes detected (config+layers+annotations), skipping manifest update for %q", volName)
+				if err := pushVolumeFilesReferrer(ctx, store, existingDesc, files); err != nil {
+					return nil, err
+				}
+				vi.VolumeRef = existingDesc.Digest.String()
+				opts.progress.total()
+				return vi, nil
+			}
 		}
 	}
 
 	manifestDesc, err := oras.PackManifest(ctx, store, oras.PackManifestVersion1_1,
-		ocispec.MediaTypeImageManifest,
+		ArtifactTypeVolume,
 		oras.PackManifestOptions{
-			ConfigDescriptor: &configDesc,
-			Layers:           layers,
-			ManifestAnnotations: map[string]string{
-				ocispec.AnnotationCreated: time.Now().UTC().Format(time.RFC3339),
-			},
+			ConfigDescriptor:    &configDesc,
+			Layers:              layers,
+			ManifestAnnotations: manifestAnnotations,
 		},
 	)
 	if err != nil {
 		return nil, transportError("VolumeIndex.publishVolumeToStore", "pack manifest", err)
 	}
+	if err := pushVolumeFilesReferrer(ctx, store, manifestDesc, files); err != nil {
+		return nil, err
+	}
 	if err := store.Tag(ctx, manifestDesc, volName); err != nil {
 		return nil, transportError("VolumeIndex.publishVolumeToStore", fmt.Sprintf("tag manifest %q", volName), err)
 	}
 	vi.VolumeRef = manifestDesc.Digest.String()
 
 	Log.Infof("Volume artifact %s tagged as %s", volName, manifestDesc.Digest)
+	opts.progress.total()
 	return vi, nil
 }
 
+// manifestUnchanged reports whether the manifest at desc already has the given
+// config, layers, and annotations, ignoring its creation timestamp.
+func manifestUnchanged(ctx context.Context, store content.Fetcher, desc, configDesc ocispec.Descriptor, layers []ocispec.Descriptor, annotations map[string]string) (bool, error) {
+	data, err := content.FetchAll(ctx, store, desc)
+	if err != nil {
+		return false, transportError("manifestUnchanged", fmt.Sprintf("fetch manifest %s", desc.Digest), err)
+	}
+	var existing ocispec.Manifest
+	if err := json.Unmarshal(data, &existing); err != nil {
+		return false, integrityError("manifestUnchanged", fmt.Sprintf("decode manifest %s", desc.Digest), err)
+	}
+	if existing.ArtifactType != ArtifactTypeVolume {
+		return false, nil
+	}
+	if existing.Config.Digest != configDesc.Digest || existing.Config.MediaType != configDesc.MediaType {
+		return false, nil
+	}
+	if len(existing.Layers) != len(layers) {
+		return false, nil
+	}
+	for i := range layers {
+		if existing.Layers[i].Digest != layers[i].Digest || !maps.Equal(existing.Layers[i].Annotations, layers[i].Annotations) {
+			return false, nil
+		}
+	}
+	withoutCreated := func(m map[string]string) map[string]string {
+		out := maps.Clone(m)
+		delete(out, ocispec.AnnotationCreated)
+		return out
+	}
+	return maps.Equal(withoutCreated(existing.Annotations), withoutCreated(annotations)), nil
+}
+
+// isVolumeManifest reports whether manifest describes a packaged volume,
+// either in the current form (ArtifactTypeVolume / MediaTypeVolumeConfig) or
+// the legacy form that reused the image config media type.
+func isVolumeManifest(manifest ocispec.Manifest) bool {
+	switch manifest.ArtifactType {
+	case ArtifactTypeVolume:
+		return true
+	case "", ocispec.MediaTypeImageManifest:
+		return manifest.Config.MediaType == MediaTypeVolumeConfig ||
+			manifest.Config.MediaType == ocispec.MediaTypeImageConfig
+	default:
+		return false
+	}
+}
+
+// partitionFSPath maps a partition path such as "vol/a/b" back onto the
+// source directory.
+func partitionFSPath(volPath, rootBase, partPath string) string {
+	if partPath == rootBase {
+		return volPath
+	}
+	return filepath.Join(volPath, strings.TrimPrefix(partPath, rootBase+"/"))
+}
+
+// childPartitionDirs returns the partitions below partPath, relative to it, so
+// an exclusive layer can leave them out.
+func childPartitionDirs(parts []Partition, partPath string) []string {
+	prefix := partPath + "/"
+	var dirs []string
+	for _, p := range parts {
+		if strings.HasPrefix(p.Path, prefix) {
+			dirs = append(dirs, strings.TrimPrefix(p.Path, prefix))
+		}
+	}
+	return dirs
+}
+
+func hasPartitionPath(parts []Partition, partPath string) bool {
+	for _, p := range parts {
+		if p.Path == partPath {
+			return true
+		}
+	}
+	return false
+}
+
+// pushBlobIfMissing pushes the blob returned by open into store unless store
+// already holds desc. open is only called when a push is needed. The result
+// reports whether the blob was pushed.
+func pushBlobIfMissing(ctx context.Context, op string, store content.Storage, desc ocispec.Descriptor, open func() (io.ReadCloser, error)) (bool, error) {
+	exists, err := store.Exists(ctx, desc)
+	if err != nil {
+		return false, transportError(op, fmt.Sprintf("check exists %s", desc.Digest), err)
+	}
+	if exists {
+		Log.Infof("blob %s already exists, skipping", desc.Digest)
+		return false, nil
+	}
+	r, err := open()
+	if err != nil {
+		return false, transportError(op, fmt.Sprintf("open blob %s", desc.Digest), err)
+	}
+	defer r.Close()
+	if err := store.Push(ctx, desc, r); err != nil {
+		if errors.Is(err, errdef.ErrAlreadyExists) {
+			return false, nil
+		}
+		return false, transportError(op, fmt.Sprintf("push blob %s", desc.Digest), err)
+	}
+	return true, nil
+}
+
 // Deprecated: prefer Client.PushPackagedVolume or PushPackagedVolume so new
 // code stays on the preferred core push path.
 func PushLocalToRemote(ctx context.Context, localRepoPath, tag, remoteRepo, user, pass string, plainHTTP bool) (*PushResult, error) {
@@ -161,18 +375,45 @@ func PushLocalToRemote(ctx context.Context, localRepoPath, tag, remoteRepo, user
 	if err != nil {
 		return nil, err
 	}
-	return pushLocalTagToRepository(ctx, localRepoPath, tag, repo)
+	return pushLocalTagToRepository(ctx, localRepoPath, tag, repo, nil)
 }
 
-func pushLocalTagToRepository(ctx context.Context, localRepoPath, tag string, repo *remote.Repository) (*PushResult, error) {
+func pushLocalTagToRepository(ctx context.Context, localRepoPath, tag string, repo *remote.Repository, progress *progressSink) (*PushResult, error) {
 	srcStore, err := oci.New(localRepoPath)
 	if err != nil {
 		return nil, transportError("pushLocalTagToRepository", "init local OCI store", err)
 	}
-	pushedDesc, err := oras.Copy(ctx, srcStore, tag, repo, tag, oras.DefaultCopyOptions)
+	var src oras.ReadOnlyTarget = srcStore
+	copyOpts := oras.DefaultCopyOptions
+	if progress != nil {
+		src = progressSource{ReadOnlyTarget: srcStore, sink: progress}
+		copyOpts.PreCopy = func(_ context.Context, desc ocispec.Descriptor) error {
+			if partPath, ok := desc.Annotations[annotationPartitionPath]; ok {
+				progress.partitionStarted(partPath, desc)
+			}
+			return nil
+		}
+		copyOpts.PostCopy = func(_ context.Context, desc ocispec.Descriptor) error {
+			if partPath, ok := desc.Annotations[annotationPartitionPath]; ok {
+				progress.layerDone(partPath, desc, desc.Size)
+			}
+			return nil
+		}
+		copyOpts.OnCopySkipped = func(_ context.Context, desc ocispec.Descriptor) error {
+			if partPath, ok := desc.Annotations[annotationPartitionPath]; ok {
+				progress.layerSkipped(partPath, desc)
+			}
+			return nil
+		}
+	}
+	pushedDesc, err := oras.Copy(ctx, src, tag, repo, tag, copyOpts)
 	if err != nil {
-		return nil, transportError("pushLocalTagToRepository", "push to remote registry", err)
+		return nil, registryError("pushLocalTagToRepository", "push to remote registry", err)
+	}
+	if err := copyVolumeFilesReferrers(ctx, "pushLocalTagToRepository", srcStore, repo, pushedDesc); err != nil {
+		return nil, err
 	}
+	progress.total()
 
 	ref := fmt.Sprintf("%s:%s", repo.Reference.String(), tag)
 	Log.Infof("Pushed to remote: %s -> %s (%s)", tag, ref, pushedDesc.Digest)
@@ -189,125 +430,172 @@ func FetchVolSeq(ctx context.Context, destRoot, repo, tag string) (*VolumeIndex,
 	if err != nil {
 		return nil, transportError("FetchVolSeq", "open OCI store", err)
 	}
+	return fetchVolumeFromTarget(ctx, "FetchVolSeq", store, repo, destRoot, tag, FetchOptions{Concurrency: 1}, nil)
+}
 
-	ref := fmt.Sprintf("%s:%s", repo, tag)
-	manifestDesc, err := store.Resolve(ctx, tag)
+func FetchVolParallel(ctx context.Context, destRoot, repo, tag string, concurrency int) (*VolumeIndex, error) {
+	store, err := oci.New(repo)
 	if err != nil {
-		return nil, notFoundError("FetchVolSeq", fmt.Sprintf("resolve reference %q", ref), err)
+		return nil, transportError("FetchVolParallel", "open OCI store", err)
+	}
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
 	}
+	return fetchVolumeFromTarget(ctx, "FetchVolParallel", store, repo, destRoot, tag, FetchOptions{Concurrency: concurrency}, nil)
+}
 
-	rc, err := store.Fetch(ctx, manifestDesc)
+// FetchRemoteVolume fetches a packaged dataset straight from a remote registry
+// without copying it into a local OCI layout first.
+//
+// ref may be a tag or a manifest digest. A concurrency of 1 extracts layers
+// sequentially; zero or less uses one worker per CPU.
+func FetchRemoteVolume(ctx context.Context, destRoot string, target RemoteTarget, ref string, concurrency int) (*VolumeIndex, error) {
+	repo, remoteRepo, err := openRemoteFetchRepository("FetchRemoteVolume", target, ref)
 	if err != nil {
-		return nil, transportError("FetchVolSeq", "fetch manifest", err)
+		return nil, err
 	}
-	defer rc.Close()
-
-	var manifest ocispec.Manifest
-	if err := json.NewDecoder(rc).Decode(&manifest); err != nil {
-		return nil, integrityError("FetchVolSeq", "decode manifest", err)
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
 	}
+	return fetchVolumeFromTarget(ctx, "FetchRemoteVolume", repo, remoteRepo, destRoot, ref, FetchOptions{Concurrency: concurrency}, nil)
+}
 
-	vi := &VolumeIndex{
-		VolumeRef:  manifestDesc.Digest.String(),
-		Partitions: make([]Partition, len(manifest.Layers)),
+func openRemoteFetchRepository(op string, target RemoteTarget, ref string) (*remote.Repository, string, error) {
+	if strings.TrimSpace(target.Registry) == "" {
+		return nil, "", validationError(op, "remote target registry is required", nil)
 	}
-	seen := make(map[string]struct{})
-
-	for i, layerDesc := range manifest.Layers {
-		layerRC, err := store.Fetch(ctx, layerDesc)
-		if err != nil {
-			return nil, transportError("FetchVolSeq", fmt.Sprintf("fetch layer %s", layerDesc.Digest), err)
-		}
-		partPath := layerDesc.Annotations["org.example.partitionPath"]
-		if partPath == "" {
-			layerRC.Close()
-			return nil, integrityError("FetchVolSeq", fmt.Sprintf("missing partitionPath annotation for layer %s", layerDesc.Digest), nil)
-		}
-		if _, dup := seen[partPath]; dup {
-			layerRC.Close()
-			return nil, conflictError("FetchVolSeq", fmt.Sprintf("duplicate partition path %q", partPath), nil)
-		}
-		seen[partPath] = struct{}{}
-
-		if err := os.MkdirAll(destRoot, 0o755); err != nil {
-			layerRC.Close()
-			return nil, transportError("FetchVolSeq", fmt.Sprintf("create destination root %s", destRoot), err)
-		}
-		if err := archiveutil.UntarGzDir(layerRC, destRoot); err != nil {
-			layerRC.Close()
-			return nil, integrityError("FetchVolSeq", fmt.Sprintf("extract layer %s", layerDesc.Digest), err)
-		}
-		if err := layerRC.Close(); err != nil {
-			return nil, transportError("FetchVolSeq", fmt.Sprintf("close layer reader %s", layerDesc.Digest), err)
-		}
-		vi.Partitions[i] = Partition{Name: partPath, Path: partPath, ManifestRef: layerDesc.Digest.String()}
+	if strings.TrimSpace(target.Repository) == "" {
+		return nil, "", validationError(op, "remote target repository is required", nil)
 	}
-
-	if err := writeVolumeIndex(destRoot, vi); err != nil {
-		return nil, err
+	if strings.TrimSpace(ref) == "" {
+		return nil, "", validationError(op, "reference is required", nil)
 	}
-	return vi, nil
-}
-
-func FetchVolParallel(ctx context.Context, destRoot, repo, tag string, concurrency int) (*VolumeIndex, error) {
-	store, err := oci.New(repo)
+	remoteRepo := remoteRepositoryRef(target)
+	repo, err := newRemoteRepository(remoteRepo, target)
 	if err != nil {
-		return nil, transportError("FetchVolParallel", "open OCI store", err)
+		return nil, "", err
 	}
+	return repo, remoteRepo, nil
+}
 
-	manifestDesc, err := store.Resolve(ctx, tag)
+// fetchVolumeFromTarget resolves ref in src and extracts every partition layer
+// of the manifest into destRoot with up to opts.Concurrency workers. srcName is
+// only used in error messages. Source selection fields of opts are ignored.
+// With opts.Atomic the extraction goes through fetchVolumeAtomic; with
+// opts.Incremental partitions already extracted with the same layer digest are
+// kept.
+func fetchVolumeFromTarget(ctx context.Context, op string, src oras.ReadOnlyTarget, srcName, destRoot, ref string, opts FetchOptions, progress *progressSink) (*VolumeIndex, error) {
+	if opts.Atomic {
+		return fetchVolumeAtomic(ctx, op, src, srcName, destRoot, ref, opts, progress)
+	}
+	manifestDesc, err := src.Resolve(ctx, ref)
 	if err != nil {
-		return nil, notFoundError("FetchVolParallel", fmt.Sprintf("resolve reference %s:%s", repo, tag), err)
+		return nil, registryError(op, fmt.Sprintf("resolve reference %s:%s", srcName, ref), err)
 	}
 
-	rc, err := store.Fetch(ctx, manifestDesc)
+	rc, err := src.Fetch(ctx, manifestDesc)
 	if err != nil {
-		return nil, transportError("FetchVolParallel", "fetch manifest", err)
+		return nil, registryError(op, "fetch manifest", err)
 	}
 	defer rc.Close()
 
 	var manifest ocispec.Manifest
 	if err := json.NewDecoder(rc).Decode(&manifest); err != nil {
-		return nil, integrityError("FetchVolParallel", "decode manifest", err)
+		return nil, integrityError(op, "decode manifest", err)
+	}
+	if !isVolumeManifest(manifest) {
+		return nil, validationError(op, fmt.Sprintf("%s:%s is not a sori volume (artifactType %q, config %q)", srcName, ref, manifest.ArtifactType, manifest.Config.MediaType), nil)
 	}
 
 	n := len(manifest.Layers)
 	vi := &VolumeIndex{
-		VolumeRef:  manifestDesc.Digest.String(),
-		Partitions: make([]Partition, n),
+		VolumeRef: manifestDesc.Digest.String(),
+		Layout:    manifest.Annotations[annotationPartitionLayout],
 	}
-
 	seen := make(map[string]struct{}, n)
 	type layerMeta struct {
-		idx  int
-		desc ocispec.Descriptor
-		path string
+		idx         int
+		desc        ocispec.Descriptor
+		path        string
+		compression string
 	}
 	metas := make([]layerMeta, 0, n)
 
-	for i, layer := range manifest.Layers {
-		partPath := layer.Annotations["org.example.partitionPath"]
+	for _, layer := range manifest.Layers {
+		partPath := layer.Annotations[annotationPartitionPath]
 		if partPath == "" {
-			return nil, integrityError("FetchVolParallel", fmt.Sprintf("missing partitionPath annotation for layer %s", layer.Digest), nil)
+			return nil, integrityError(op, fmt.Sprintf("missing partitionPath annotation for layer %s", layer.Digest), nil)
 		}
 		if _, dup := seen[partPath]; dup {
-			return nil, conflictError("FetchVolParallel", fmt.Sprintf("duplicate partition path %q", partPath), nil)
+			return nil, conflictError(op, fmt.Sprintf("duplicate partition path %q", partPath), nil)
 		}
 		seen[partPath] = struct{}{}
-		metas = append(metas, layerMeta{i, layer, partPath})
+		compression, err := layerCompression(layer.MediaType)
+		if err != nil {
+			return nil, err
+		}
+		metas = append(metas, layerMeta{len(metas), layer, partPath, compression})
+	}
+	n = len(metas)
+	vi.Partitions = make([]Partition, n)
+	if len(opts.Partitions) > 0 {
+		paths := make([]string, len(metas))
+		for i, m := range metas {
+			paths[i] = m.path
+		}
+		selected, err := selectPartitions(op, paths, vi.Layout, opts.Partitions)
+		if err != nil {
+			return nil, err
+		}
+		kept := metas[:0]
+		for _, m := range metas {
+			if _, ok := selected[m.path]; ok {
+				m.idx = len(kept)
+				kept = append(kept, m)
+			}
+		}
+		metas = kept
+		n = len(metas)
+		vi.Partitions = make([]Partition, n)
 	}
 
-	if concurrency <= 0 || concurrency > n {
-		cpu := runtime.NumCPU()
-		if cpu < 1 {
-			cpu = 1
+	if opts.Incremental {
+		wanted := make(map[string]string, len(metas))
+		for _, m := range metas {
+			wanted[m.path] = m.desc.Digest.String()
 		}
-		if cpu > n {
-			cpu = n
+		reused, err := prepareIncrementalFetch(op, destRoot, vi.Layout, wanted)
+		if err != nil {
+			return nil, err
 		}
-		concurrency = cpu
+		changed := metas[:0]
+		for _, m := range metas {
+			if _, ok := reused[m.path]; !ok {
+				changed = append(changed, m)
+				continue
+			}
+			progress.layerSkipped(m.path, m.desc)
+			vi.Partitions[m.idx] = Partition{
+				Name:        m.path,
+				Path:        m.path,
+				ManifestRef: m.desc.Digest.String(),
+				Compression: m.compression,
+				CacheStatus: CacheReused,
+			}
+		}
+		metas = changed
+		n = len(metas)
 	}
 
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	if concurrency > n {
+		concurrency = n
+	}
+	budget := archiveutil.NewExtractBudget(opts.Limits)
+
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
@@ -330,33 +618,42 @@ func FetchVolParallel(ctx context.Context, destRoot, repo, tag string, concurren
 			default:
 			}
 
-			layerRC, err := store.Fetch(ctx, meta.desc)
+			progress.partitionStarted(meta.path, meta.desc)
+			fetchedRC, cacheStatus, err := fetchLayer(ctx, src, meta.desc)
 			if err != nil {
-				results <- jobResult{idx: meta.idx, err: transportError("FetchVolParallel", fmt.Sprintf("fetch layer %s", meta.desc.Digest), err)}
+				results <- jobResult{idx: meta.idx, err: registryError(op, fmt.Sprintf("fetch layer %s", meta.desc.Digest), err)}
 				cancel()
 				continue
 			}
+			layerRC := &progressReader{ReadCloser: fetchedRC, onRead: progress.counter(meta.path, meta.desc)}
 			if err := os.MkdirAll(destRoot, 0o755); err != nil {
 				layerRC.Close()
-				results <- jobResult{idx: meta.idx, err: transportError("FetchVolParallel", fmt.Sprintf("mkdir %s", destRoot), err)}
+				results <- jobResult{idx: meta.idx, err: transportError(op, fmt.Sprintf("create destination root %s", destRoot), err)}
 				cancel()
 				continue
 			}
-			if err := archiveutil.UntarGzDir(layerRC, destRoot); err != nil {
+			if err := archiveutil.UntarDirWithBudget(layerRC, destRoot, meta.compression, budget); err != nil {
 				layerRC.Close()
-				results <- jobResult{idx: meta.idx, err: integrityError("FetchVolParallel", fmt.Sprintf("extract layer %s", meta.desc.Digest), err)}
+				results <- jobResult{idx: meta.idx, err: integrityError(op, fmt.Sprintf("extract layer %s", meta.desc.Digest), err)}
 				cancel()
 				continue
 			}
 			if err := layerRC.Close(); err != nil {
-				results <- jobResult{idx: meta.idx, err: transportError("FetchVolParallel", fmt.Sprintf("close reader %s", meta.desc.Digest), err)}
+				results <- jobResult{idx: meta.idx, err: transportError(op, fmt.Sprintf("close layer reader %s", meta.desc.Digest), err)}
 				cancel()
 				continue
 			}
 
+			progress.layerDone(meta.path, meta.desc, layerRC.n)
 			results <- jobResult{
 				idx: meta.idx,
-				p:   Partition{Name: meta.path, Path: meta.path, ManifestRef: meta.desc.Digest.String()},
+				p: Partition{
+					Name:        meta.path,
+					Path:        meta.path,
+					ManifestRef: meta.desc.Digest.String(),
+					Compression: meta.compression,
+					CacheStatus: cacheStatus,
+				},
 			}
 		}
 	}
@@ -396,9 +693,23 @@ func FetchVolParallel(ctx context.Context, destRoot, repo, tag string, concurren
 	if firstErr != nil {
 		return nil, firstErr
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, transportError(op, "fetch canceled", err)
+	}
+	// The file manifest is optional metadata: a volume without one, or a
+	// registry that cannot list referrers, still fetches.
+	files, err := fetchVolumeFiles(ctx, op, src, manifestDesc)
+	if err != nil {
+		Log.Warnf("skipping %s for %s: %v", VolumeFilesJson, manifestDesc.Digest, err)
+	} else if files != nil {
+		if err := writeVolumeFiles(op, files, destRoot, vi.Partitions); err != nil {
+			return nil, err
+		}
+	}
 	if err := writeVolumeIndex(destRoot, vi); err != nil {
 		return nil, err
 	}
+	progress.total()
 	return vi, nil
 }
 
@@ -410,7 +721,7 @@ func pushDataSpecManifest(ctx context.Context, target content.Pusher, subjectDes
 
 	configDesc, err := oras.PushBytes(ctx, target, DataSpecMediaType, specBytes)
 	if err != nil {
-		return nil, transportError("pushDataSpecManifest", "push data spec blob", err)
+		return nil, registryError("pushDataSpecManifest", "push data spec blob", err)
 	}
 
 	manifestDesc, err := oras.PackManifest(ctx, target, oras.PackManifestVersion1_1,
@@ -424,7 +735,7 @@ func pushDataSpecManifest(ctx context.Context, target content.Pusher, subjectDes
 		},
 	)
 	if err != nil {
-		return nil, transportError("pushDataSpecManifest", "pack data spec manifest", err)
+		return nil, registryError("pushDataSpecManifest", "pack data spec manifest", err)
 	}
 
 	return &ReferrerPushResult{
@@ -0,0 +1,90 @@
+package sori
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// selectPartitions returns the subset of partition paths that selectors pick,
+// following the rules documented on FetchOptions.Partitions.
+func selectPartitions(op string, partitions []string, layout string, selectors []string) (map[string]struct{}, error) {
+	selected := make(map[string]struct{})
+	for _, raw := range selectors {
+		sel := path.Clean(strings.TrimSpace(raw))
+		if sel == "." || strings.HasPrefix(sel, "../") || sel == ".." || path.IsAbs(sel) {
+			return nil, validationError(op, fmt.Sprintf("invalid partition selector %q", raw), nil)
+		}
+		if _, err := path.Match(sel, ""); err != nil {
+			return nil, validationError(op, fmt.Sprintf("invalid partition pattern %q", raw), err)
+		}
+
+		var matched []string
+		for _, p := range partitions {
+			if partitionMatches(p, sel) {
+				matched = append(matched, p)
+			}
+		}
+		if len(matched) == 0 {
+			// A plain path below a partition resolves to the deepest
+			// partition that holds it.
+			if owner := owningPartition(partitions, sel); owner != "" {
+				matched = append(matched, owner)
+			}
+		}
+		if len(matched) == 0 {
+			return nil, notFoundError(op, fmt.Sprintf("no partition matches %q", raw), nil)
+		}
+		for _, p := range matched {
+			selected[p] = struct{}{}
+		}
+	}
+
+	if layout == PartitionLayoutExclusive {
+		for _, p := range partitions {
+			for s := range selected {
+				if isSubPath(s, p) {
+					selected[p] = struct{}{}
+					break
+				}
+			}
+		}
+	}
+	return selected, nil
+}
+
+// partitionMatches reports whether sel names or matches partition p, either
+// as a whole or without its leading volume directory.
+func partitionMatches(p, sel string) bool {
+	if ok, _ := path.Match(sel, p); ok {
+		return true
+	}
+	if _, rel, found := strings.Cut(p, "/"); found {
+		ok, _ := path.Match(sel, rel)
+		return ok
+	}
+	return false
+}
+
+// owningPartition returns the deepest partition that is an ancestor of sel,
+// or "" when none is.
+func owningPartition(partitions []string, sel string) string {
+	owner := ""
+	for _, p := range partitions {
+		candidates := []string{p}
+		if _, rel, found := strings.Cut(p, "/"); found {
+			candidates = append(candidates, rel)
+		}
+		for _, c := range candidates {
+			if isSubPath(c, sel) && len(p) > len(owner) {
+				owner = p
+			}
+		}
+	}
+	return owner
+}
+
+// isSubPath reports whether child lies strictly below parent.
+func isSubPath(parent, child string) bool {
+	return strings.HasPrefix(child, parent+"/")
+}
@@ -0,0 +1,98 @@
+package sori
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSelectPartitions(t *testing.T) {
+	partitions := []string{"vol", "vol/annotation", "vol/chr1", "vol/chr1/index", "vol/chr2", "vol/raw"}
+	cases := []struct {
+		name      string
+		layout    string
+		selectors []string
+		want      []string
+	}{
+		{"full path", PartitionLayoutNested, []string{"vol/annotation"}, []string{"vol/annotation"}},
+		{"without volume dir", PartitionLayoutNested, []string{"annotation/"}, []string{"vol/annotation"}},
+		{"glob", PartitionLayoutNested, []string{"chr*"}, []string{"vol/chr1", "vol/chr2"}},
+		{"path inside partition", PartitionLayoutNested, []string{"vol/raw/reads/r1.fq"}, []string{"vol/raw"}},
+		{"deepest owner", PartitionLayoutNested, []string{"chr1/index/part.bin"}, []string{"vol/chr1/index"}},
+		{"exclusive brings children", PartitionLayoutExclusive, []string{"chr1"}, []string{"vol/chr1", "vol/chr1/index"}},
+		{"nested leaves children", PartitionLayoutNested, []string{"chr1"}, []string{"vol/chr1"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			selected, err := selectPartitions("test", partitions, tc.layout, tc.selectors)
+			if err != nil {
+				t.Fatalf("selectPartitions: %v", err)
+			}
+			got := make([]string, 0, len(selected))
+			for p := range selected {
+				got = append(got, p)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %v want %v", got, tc.want)
+			}
+		})
+	}
+
+	if _, err := selectPartitions("test", partitions, "", []string{"chrX"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for unmatched selector, got %v", err)
+	}
+	for _, bad := range []string{"[", "../vol", "/vol", ""} {
+		if _, err := selectPartitions("test", partitions, "", []string{bad}); !errors.Is(err, ErrValidation) {
+			t.Fatalf("expected ErrValidation for %q, got %v", bad, err)
+		}
+	}
+}
+
+func TestClientFetchVolume_SelectedPartitions(t *testing.T) {
+	for _, layout := range []string{PartitionLayoutNested, PartitionLayoutExclusive} {
+		t.Run(layout, func(t *testing.T) {
+			ctx := context.Background()
+			storePath := filepath.Join(t.TempDir(), "oci")
+			client := NewClient(WithLocalStorePath(storePath))
+			src := filepath.Join(t.TempDir(), "vol")
+			writeTestVolume(t, src, "payload")
+			if _, err := client.PackageVolumeWithOptions(ctx, PackageRequest{SourceDir: src, DisplayName: "Sel", Tag: "sel.v1"}, PackageOptions{PartitionLayout: layout}); err != nil {
+				t.Fatalf("PackageVolume: %v", err)
+			}
+
+			dest := filepath.Join(t.TempDir(), "restored")
+			vi, err := client.FetchVolume(ctx, dest, storePath, "sel.v1", FetchOptions{Partitions: []string{"a"}})
+			if err != nil {
+				t.Fatalf("FetchVolume: %v", err)
+			}
+			if len(vi.Partitions) != 1 || vi.Partitions[0].Path != "vol/a" {
+				t.Fatalf("expected only vol/a, got %+v", vi.Partitions)
+			}
+			if _, err := os.Stat(filepath.Join(dest, "vol", "a", "a.txt")); err != nil {
+				t.Fatalf("expected selected file: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(dest, "vol", "b")); !os.IsNotExist(err) {
+				t.Fatalf("expected vol/b to be skipped, got %v", err)
+			}
+
+			data, err := os.ReadFile(filepath.Join(dest, VolumeIndexJson))
+			if err != nil {
+				t.Fatalf("read volume index: %v", err)
+			}
+			var written VolumeIndex
+			if err := json.Unmarshal(data, &written); err != nil || len(written.Partitions) != 1 {
+				t.Fatalf("volume index must list only fetched partitions: %+v, %v", written, err)
+			}
+
+			if _, err := client.FetchVolume(ctx, t.TempDir(), storePath, "sel.v1", FetchOptions{Partitions: []string{"missing"}}); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("expected ErrNotFound for unmatched selector, got %v", err)
+			}
+		})
+	}
+}
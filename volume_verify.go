@@ -0,0 +1,383 @@
+package sori
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	godigest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/oci"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry"
+)
+
+// VolumeFile is one regular file recorded in a volume's file manifest. Path
+// is slash-separated and starts with the volume directory, as in partition
+// paths.
+type VolumeFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// VolumeFiles is the file manifest packaged with a volume and written to
+// volume-files.json next to volume-index.json on fetch.
+type VolumeFiles struct {
+	Files []VolumeFile `json:"files"`
+}
+
+// volumeFilesConfig is the config blob of the file manifest referrer.
+type volumeFilesConfig struct {
+	Files int   `json:"files"`
+	Size  int64 `json:"size"`
+}
+
+// VerifyReport lists the differences VerifyVolume found between an extracted
+// volume and its file manifest. Paths are sorted.
+type VerifyReport struct {
+	// Verified counts files whose size and sha256 match.
+	Verified int      `json:"verified"`
+	Missing  []string `json:"missing"`
+	// Modified lists files whose size or sha256 differ from the manifest.
+	Modified []string `json:"modified"`
+	// Extra lists files on disk that the manifest does not record.
+	Extra []string `json:"extra"`
+}
+
+// OK reports whether the volume matched its file manifest exactly.
+func (r *VerifyReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Modified) == 0 && len(r.Extra) == 0
+}
+
+// VerifyVolume checks the volume fetched into destRoot against the
+// volume-files.json written by the fetch, hashing every regular file. It
+// returns ErrNotFound when destRoot has no file manifest, which is the case
+// for volumes packaged before file manifests were recorded. Differences are
+// reported in the result, not as an error.
+func (c *Client) VerifyVolume(ctx context.Context, destRoot string) (*VerifyReport, error) {
+	return verifyVolumeDir(ctx, "Client.VerifyVolume", destRoot)
+}
+
+// verifyVolumeDir is VerifyVolume for op.
+func verifyVolumeDir(ctx context.Context, op, destRoot string) (*VerifyReport, error) {
+	data, err := os.ReadFile(filepath.Join(destRoot, VolumeFilesJson))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, notFoundError(op, fmt.Sprintf("%s has no %s", destRoot, VolumeFilesJson), err)
+		}
+		return nil, transportError(op, "read "+VolumeFilesJson, err)
+	}
+	var manifest VolumeFiles
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, integrityError(op, "decode "+VolumeFilesJson, err)
+	}
+	expected := make(map[string]VolumeFile, len(manifest.Files))
+	for _, f := range manifest.Files {
+		expected[f.Path] = f
+	}
+
+	report := &VerifyReport{Missing: []string{}, Modified: []string{}, Extra: []string{}}
+	seen := make(map[string]struct{}, len(expected))
+	err = filepath.WalkDir(destRoot, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return transportError(op, fmt.Sprintf("access %s", path), err)
+		}
+		if err := ctx.Err(); err != nil {
+			return transportError(op, "verify canceled", err)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(destRoot, path)
+		if err != nil {
+			return transportError(op, fmt.Sprintf("get rel path for %s", path), err)
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == VolumeIndexJson || rel == VolumeFilesJson {
+			return nil
+		}
+		want, ok := expected[rel]
+		if !ok {
+			report.Extra = append(report.Extra, rel)
+			return nil
+		}
+		seen[rel] = struct{}{}
+		match, err := fileMatches(path, want)
+		if err != nil {
+			return transportError(op, fmt.Sprintf("hash %s", path), err)
+		}
+		if match {
+			report.Verified++
+		} else {
+			report.Modified = append(report.Modified, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for p := range expected {
+		if _, ok := seen[p]; !ok {
+			report.Missing = append(report.Missing, p)
+		}
+	}
+	sort.Strings(report.Missing)
+	sort.Strings(report.Modified)
+	sort.Strings(report.Extra)
+	return report, nil
+}
+
+// fileMatches compares the size and sha256 of the file at path with want.
+func fileMatches(path string, want VolumeFile) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	if info.Size() != want.Size {
+		return false, nil
+	}
+	digester := godigest.SHA256.Digester()
+	if _, err := io.Copy(digester.Hash(), f); err != nil {
+		return false, err
+	}
+	return digester.Digest().String() == want.SHA256, nil
+}
+
+// pushVolumeFilesReferrer attaches the file manifest built during packaging
+// to the volume manifest subject as a referrer, so the volume manifest itself
+// keeps only partition layers. A referrer with the same file manifest that is
+// already attached is reused; any other file manifest referrer of subject is
+// superseded and deleted from the store.
+func pushVolumeFilesReferrer(ctx context.Context, store *oci.Store, subject ocispec.Descriptor, files map[string]VolumeFile) error {
+	const op = "VolumeIndex.publishVolumeToStore"
+	list := VolumeFiles{Files: make([]VolumeFile, 0, len(files))}
+	for _, f := range files {
+		list.Files = append(list.Files, f)
+	}
+	sort.Slice(list.Files, func(i, j int) bool { return list.Files[i].Path < list.Files[j].Path })
+	data, err := json.Marshal(list)
+	if err != nil {
+		return transportError(op, "marshal file manifest", err)
+	}
+	desc := ocispec.Descriptor{
+		MediaType: MediaTypeVolumeFiles,
+		Digest:    godigest.FromBytes(data),
+		Size:      int64(len(data)),
+		Annotations: map[string]string{
+			ocispec.AnnotationTitle: VolumeFilesJson,
+		},
+	}
+	subject = ocispec.Descriptor{MediaType: subject.MediaType, Digest: subject.Digest, Size: subject.Size}
+
+	existing, err := volumeFilesReferrers(ctx, op, store, subject)
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 && existing[0].layer.Digest == desc.Digest {
+		return deleteVolumeFilesReferrers(ctx, op, store, existing[1:])
+	}
+	if _, err := pushBlobIfMissing(ctx, op, store, desc, func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}); err != nil {
+		return err
+	}
+	// A summary config instead of the default empty JSON config keeps the
+	// referrer from sharing a digest with a volume whose config blob is "{}",
+	// which the store's garbage collection would try to delete twice.
+	var total int64
+	for _, f := range list.Files {
+		total += f.Size
+	}
+	configData, err := json.Marshal(volumeFilesConfig{Files: len(list.Files), Size: total})
+	if err != nil {
+		return transportError(op, "marshal file manifest config", err)
+	}
+	configDesc := ocispec.Descriptor{
+		MediaType: mediaTypeVolumeFilesConfig,
+		Digest:    godigest.FromBytes(configData),
+		Size:      int64(len(configData)),
+	}
+	if _, err := pushBlobIfMissing(ctx, op, store, configDesc, func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(configData)), nil
+	}); err != nil {
+		return err
+	}
+	if _, err := oras.PackManifest(ctx, store, oras.PackManifestVersion1_1, MediaTypeVolumeFiles, oras.PackManifestOptions{
+		Subject:          &subject,
+		ConfigDescriptor: &configDesc,
+		Layers:           []ocispec.Descriptor{desc},
+	}); err != nil {
+		return transportError(op, "pack file manifest referrer", err)
+	}
+	return deleteVolumeFilesReferrers(ctx, op, store, existing)
+}
+
+func deleteVolumeFilesReferrers(ctx context.Context, op string, store *oci.Store, stale []volumeFilesCandidate) error {
+	for _, c := range stale {
+		if err := store.Delete(ctx, c.referrer); err != nil && !errors.Is(err, errdef.ErrNotFound) {
+			return transportError(op, fmt.Sprintf("delete superseded file manifest referrer %s", c.referrer.Digest), err)
+		}
+	}
+	return nil
+}
+
+// volumeFilesCandidate is a file manifest referrer and its file manifest
+// layer.
+type volumeFilesCandidate struct {
+	referrer ocispec.Descriptor
+	layer    ocispec.Descriptor
+	created  string
+}
+
+// volumeFilesReferrers lists the file manifest referrers of subject in graph,
+// newest first by their created annotation and then by digest, so every
+// reader picks the same one when a registry holds more than one.
+func volumeFilesReferrers(ctx context.Context, op string, graph content.ReadOnlyGraphStorage, subject ocispec.Descriptor) ([]volumeFilesCandidate, error) {
+	referrers, err := registry.Referrers(ctx, graph, subject, MediaTypeVolumeFiles)
+	if err != nil {
+		return nil, registryError(op, fmt.Sprintf("list file manifest referrers of %s", subject.Digest), err)
+	}
+	var candidates []volumeFilesCandidate
+	for _, referrer := range referrers {
+		data, err := content.FetchAll(ctx, graph, referrer)
+		if err != nil {
+			return nil, registryError(op, fmt.Sprintf("fetch file manifest referrer %s", referrer.Digest), err)
+		}
+		var manifest ocispec.Manifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil, integrityError(op, fmt.Sprintf("decode file manifest referrer %s", referrer.Digest), err)
+		}
+		for _, layer := range manifest.Layers {
+			if layer.MediaType == MediaTypeVolumeFiles {
+				candidates = append(candidates, volumeFilesCandidate{
+					referrer: referrer,
+					layer:    layer,
+					created:  manifest.Annotations[ocispec.AnnotationCreated],
+				})
+				break
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		ti, _ := time.Parse(time.RFC3339, candidates[i].created)
+		tj, _ := time.Parse(time.RFC3339, candidates[j].created)
+		if !ti.Equal(tj) {
+			return ti.After(tj)
+		}
+		return candidates[i].referrer.Digest > candidates[j].referrer.Digest
+	})
+	return candidates, nil
+}
+
+// volumeFilesReferrer returns the newest file manifest referrer of subject in
+// graph and its file manifest layer, or a nil layer when there is none.
+func volumeFilesReferrer(ctx context.Context, op string, graph content.ReadOnlyGraphStorage, subject ocispec.Descriptor) (ocispec.Descriptor, *ocispec.Descriptor, error) {
+	candidates, err := volumeFilesReferrers(ctx, op, graph, subject)
+	if err != nil || len(candidates) == 0 {
+		return ocispec.Descriptor{}, nil, err
+	}
+	return candidates[0].referrer, &candidates[0].layer, nil
+}
+
+// fetchVolumeFiles reads the file manifest attached to subject in src. It
+// returns nil without error when the volume has none or src cannot list
+// referrers. A pull-through source looks in its local store first and caches
+// a referrer found on the remote.
+func fetchVolumeFiles(ctx context.Context, op string, src oras.ReadOnlyTarget, subject ocispec.Descriptor) (*VolumeFiles, error) {
+	var graph content.ReadOnlyGraphStorage
+	var desc *ocispec.Descriptor
+	if pt, ok := src.(*pullThroughTarget); ok {
+		_, layer, err := volumeFilesReferrer(ctx, op, pt.cache, subject)
+		if err != nil {
+			return nil, err
+		}
+		graph, desc = pt.cache, layer
+		if desc == nil {
+			remote, ok := pt.remote.(content.ReadOnlyGraphStorage)
+			if !ok {
+				return nil, nil
+			}
+			referrer, layer, err := volumeFilesReferrer(ctx, op, remote, subject)
+			if err != nil || layer == nil {
+				return nil, err
+			}
+			if err := oras.CopyGraph(ctx, remote, pt.cache, referrer, oras.DefaultCopyGraphOptions); err != nil {
+				return nil, registryError(op, fmt.Sprintf("cache file manifest referrer %s", referrer.Digest), err)
+			}
+			desc = layer
+		}
+	} else {
+		var ok bool
+		if graph, ok = src.(content.ReadOnlyGraphStorage); !ok {
+			return nil, nil
+		}
+		_, layer, err := volumeFilesReferrer(ctx, op, graph, subject)
+		if err != nil || layer == nil {
+			return nil, err
+		}
+		desc = layer
+	}
+	data, err := content.FetchAll(ctx, graph, *desc)
+	if err != nil {
+		return nil, registryError(op, fmt.Sprintf("fetch file manifest %s", desc.Digest), err)
+	}
+	var files VolumeFiles
+	if err := json.Unmarshal(data, &files); err != nil {
+		return nil, integrityError(op, "decode file manifest", err)
+	}
+	return &files, nil
+}
+
+// copyVolumeFilesReferrers copies the file manifest referrers of subject from
+// src to dst, so a pushed volume can still be verified after a remote fetch.
+func copyVolumeFilesReferrers(ctx context.Context, op string, src content.ReadOnlyGraphStorage, dst oras.Target, subject ocispec.Descriptor) error {
+	referrers, err := registry.Referrers(ctx, src, subject, MediaTypeVolumeFiles)
+	if err != nil {
+		return transportError(op, fmt.Sprintf("list file manifest referrers of %s", subject.Digest), err)
+	}
+	for _, referrer := range referrers {
+		if err := oras.CopyGraph(ctx, src, dst, referrer, oras.DefaultCopyGraphOptions); err != nil {
+			return registryError(op, fmt.Sprintf("push file manifest referrer %s", referrer.Digest), err)
+		}
+	}
+	return nil
+}
+
+// writeVolumeFiles writes the entries of files that belong to the fetched
+// partitions to destRoot/volume-files.json.
+func writeVolumeFiles(op string, files *VolumeFiles, destRoot string, partitions []Partition) error {
+	kept := VolumeFiles{Files: make([]VolumeFile, 0, len(files.Files))}
+	for _, f := range files.Files {
+		for _, p := range partitions {
+			if strings.HasPrefix(f.Path, p.Path+"/") {
+				kept.Files = append(kept.Files, f)
+				break
+			}
+		}
+	}
+	out, err := json.MarshalIndent(kept, "", "  ")
+	if err != nil {
+		return transportError(op, "marshal file manifest", err)
+	}
+	if err := os.WriteFile(filepath.Join(destRoot, VolumeFilesJson), out, 0o644); err != nil {
+		return transportError(op, "write "+VolumeFilesJson, err)
+	}
+	return nil
+}
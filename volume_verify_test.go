@@ -0,0 +1,223 @@
+package sori
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/seoyhaein/sori/archiveutil"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/oci"
+)
+
+func TestClientVerifyVolume(t *testing.T) {
+	ctx := context.Background()
+	storePath := filepath.Join(t.TempDir(), "oci")
+	client := NewClient(WithLocalStorePath(storePath))
+	src := filepath.Join(t.TempDir(), "vol")
+	writeTestVolume(t, src, "payload")
+	if err := os.MkdirAll(filepath.Join(src, "a", "deep"), 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "a", "deep", "d.txt"), []byte("deep"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := client.PackageVolume(ctx, PackageRequest{SourceDir: src, DisplayName: "Verify", Tag: "verify.v1"}); err != nil {
+		t.Fatalf("PackageVolume: %v", err)
+	}
+
+	dest := filepath.Join(t.TempDir(), "restored")
+	if _, err := client.FetchVolume(ctx, dest, storePath, "verify.v1", FetchOptions{Concurrency: 2}); err != nil {
+		t.Fatalf("FetchVolume: %v", err)
+	}
+	report, err := client.VerifyVolume(ctx, dest)
+	if err != nil {
+		t.Fatalf("VerifyVolume: %v", err)
+	}
+	if !report.OK() || report.Verified != 3 {
+		t.Fatalf("expected 3 verified files, got %+v", report)
+	}
+
+	if err := os.WriteFile(filepath.Join(dest, "vol", "a", "a.txt"), []byte("PAYLOAD"), 0o644); err != nil {
+		t.Fatalf("modify: %v", err)
+	}
+	if err := os.Remove(filepath.Join(dest, "vol", "b", "b.txt")); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dest, "vol", "b", "c.txt"), []byte("extra"), 0o644); err != nil {
+		t.Fatalf("extra: %v", err)
+	}
+	report, err = client.VerifyVolume(ctx, dest)
+	if err != nil {
+		t.Fatalf("VerifyVolume: %v", err)
+	}
+	want := &VerifyReport{
+		Verified: 1,
+		Missing:  []string{"vol/b/b.txt"},
+		Modified: []string{"vol/a/a.txt"},
+		Extra:    []string{"vol/b/c.txt"},
+	}
+	if report.OK() || !reflect.DeepEqual(report, want) {
+		t.Fatalf("got %+v want %+v", report, want)
+	}
+
+	partial := filepath.Join(t.TempDir(), "partial")
+	if _, err := client.FetchVolume(ctx, partial, storePath, "verify.v1", FetchOptions{Partitions: []string{"b"}}); err != nil {
+		t.Fatalf("FetchVolume partial: %v", err)
+	}
+	if report, err := client.VerifyVolume(ctx, partial); err != nil || !report.OK() || report.Verified != 1 {
+		t.Fatalf("partial fetch must verify against its own partitions: %+v, %v", report, err)
+	}
+
+	if err := os.Remove(filepath.Join(partial, VolumeFilesJson)); err != nil {
+		t.Fatalf("remove file manifest: %v", err)
+	}
+	if _, err := client.VerifyVolume(ctx, partial); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound without a file manifest, got %v", err)
+	}
+}
+
+// TestPackageVolume_BaselineFetcherCompatible walks the manifest the way
+// fetchers released before file manifests do: every layer must carry a
+// partition path and extract as a gzip tar.
+func TestPackageVolume_BaselineFetcherCompatible(t *testing.T) {
+	ctx := context.Background()
+	storePath := filepath.Join(t.TempDir(), "oci")
+	client := NewClient(WithLocalStorePath(storePath))
+	src := filepath.Join(t.TempDir(), "vol")
+	writeTestVolume(t, src, "payload")
+	if _, err := client.PackageVolume(ctx, PackageRequest{SourceDir: src, DisplayName: "Baseline", Tag: "baseline.v1"}); err != nil {
+		t.Fatalf("PackageVolume: %v", err)
+	}
+
+	store, err := oci.New(storePath)
+	if err != nil {
+		t.Fatalf("oci.New: %v", err)
+	}
+	dest := t.TempDir()
+	for _, layer := range readTestManifest(t, storePath, "baseline.v1").Layers {
+		if layer.Annotations[annotationPartitionPath] == "" {
+			t.Fatalf("layer %s (%s) has no partition path; baseline fetchers reject it", layer.Digest, layer.MediaType)
+		}
+		rc, err := store.Fetch(ctx, layer)
+		if err != nil {
+			t.Fatalf("Fetch layer: %v", err)
+		}
+		err = archiveutil.UntarGzDir(rc, dest)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("UntarGzDir %s: %v", layer.Digest, err)
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(dest, "vol", "a", "a.txt")); err != nil || string(data) != "payload" {
+		t.Fatalf("baseline extraction: %q, %v", data, err)
+	}
+}
+
+func TestClientVerifyVolume_AfterRemoteFetch(t *testing.T) {
+	ctx := context.Background()
+	reg := newTestRegistry(t, filepath.Join(t.TempDir(), "registry"))
+	target := reg.target("data/ref")
+	client := NewClient(WithLocalStorePath(filepath.Join(t.TempDir(), "oci")))
+	src := filepath.Join(t.TempDir(), "vol")
+	writeTestVolume(t, src, "payload")
+	pkg, err := client.PackageVolume(ctx, PackageRequest{SourceDir: src, DisplayName: "Remote", Tag: "remote.v1"})
+	if err != nil {
+		t.Fatalf("PackageVolume: %v", err)
+	}
+	if _, err := client.PushPackagedVolume(ctx, pkg, target); err != nil {
+		t.Fatalf("PushPackagedVolume: %v", err)
+	}
+
+	dest := filepath.Join(t.TempDir(), "restored")
+	if _, err := client.FetchVolume(ctx, dest, "", "remote.v1", FetchOptions{Remote: &target}); err != nil {
+		t.Fatalf("FetchVolume: %v", err)
+	}
+	report, err := client.VerifyVolume(ctx, dest)
+	if err != nil {
+		t.Fatalf("VerifyVolume after remote fetch: %v", err)
+	}
+	if !report.OK() || report.Verified != 2 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+}
+
+func TestVolumeFilesReferrer_SupersededAndNewest(t *testing.T) {
+	ctx := context.Background()
+	storePath := filepath.Join(t.TempDir(), "oci")
+	client := NewClient(WithLocalStorePath(storePath))
+	src := filepath.Join(t.TempDir(), "vol")
+	writeTestVolume(t, src, "payload")
+	if _, err := client.PackageVolume(ctx, PackageRequest{SourceDir: src, DisplayName: "Verify", Tag: "verify.v1"}); err != nil {
+		t.Fatalf("PackageVolume: %v", err)
+	}
+	store, err := oci.New(storePath)
+	if err != nil {
+		t.Fatalf("oci.New: %v", err)
+	}
+	subject, err := store.Resolve(ctx, "verify.v1")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	filesOf := func() []VolumeFile {
+		t.Helper()
+		files, err := fetchVolumeFiles(ctx, "test", store, subject)
+		if err != nil || files == nil {
+			t.Fatalf("fetchVolumeFiles: %v, %v", files, err)
+		}
+		return files.Files
+	}
+	referrerCount := func() int {
+		t.Helper()
+		candidates, err := volumeFilesReferrers(ctx, "test", store, subject)
+		if err != nil {
+			t.Fatalf("volumeFilesReferrers: %v", err)
+		}
+		return len(candidates)
+	}
+
+	replaced := map[string]VolumeFile{"vol/new.txt": {Path: "vol/new.txt", Size: 1, SHA256: "00"}}
+	if err := pushVolumeFilesReferrer(ctx, store, subject, replaced); err != nil {
+		t.Fatalf("pushVolumeFilesReferrer: %v", err)
+	}
+	if n := referrerCount(); n != 1 {
+		t.Fatalf("superseded file manifest must be deleted, got %d referrers", n)
+	}
+	if got := filesOf(); len(got) != 1 || got[0].Path != "vol/new.txt" {
+		t.Fatalf("expected the replacing file manifest, got %+v", got)
+	}
+
+	// A registry may still hold several; readers take the newest.
+	attach := func(created, path string) {
+		t.Helper()
+		data, _ := json.Marshal(VolumeFiles{Files: []VolumeFile{{Path: path}}})
+		layer := content.NewDescriptorFromBytes(MediaTypeVolumeFiles, data)
+		if err := store.Push(ctx, layer, bytes.NewReader(data)); err != nil {
+			t.Fatalf("push layer: %v", err)
+		}
+		if _, err := oras.PackManifest(ctx, store, oras.PackManifestVersion1_1, MediaTypeVolumeFiles, oras.PackManifestOptions{
+			Subject:             &subject,
+			Layers:              []ocispec.Descriptor{layer},
+			ManifestAnnotations: map[string]string{ocispec.AnnotationCreated: created},
+		}); err != nil {
+			t.Fatalf("PackManifest: %v", err)
+		}
+	}
+	attach("2000-01-01T00:00:00Z", "vol/old.txt")
+	for i := 0; i < 5; i++ {
+		if got := filesOf(); got[0].Path != "vol/new.txt" {
+			t.Fatalf("an older referrer was picked: %+v", got)
+		}
+	}
+	attach("2100-01-01T00:00:00Z", "vol/newest.txt")
+	if got := filesOf(); got[0].Path != "vol/newest.txt" {
+		t.Fatalf("expected the newest referrer, got %+v", got)
+	}
+}